@@ -0,0 +1,73 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package command
+
+import (
+	"fmt"
+	"os"
+
+	ucfg "github.com/elastic/go-ucfg"
+	"github.com/elastic/go-ucfg/yaml"
+	"github.com/spf13/pflag"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+// fileConfig holds the settings of a --config file that are not output
+// options.
+type fileConfig struct {
+	Fanout []string `config:"fanout"` // --fanout entries.
+}
+
+// loadConfig loads the output options in the YAML file at path into opts and
+// returns its fanout entries. ${VAR} references are replaced with environment
+// variables. Flags in flags that were set on the command line or from an
+// environment variable take precedence over the file, so the precedence is
+// defaults, then the file, then environment variables, then flags.
+func loadConfig(path string, flags *pflag.FlagSet, opts *output.Options) ([]string, error) {
+	cfgOpts := []ucfg.Option{ucfg.PathSep("."), ucfg.VarExp, ucfg.ResolveEnv}
+	cfg, err := yaml.NewConfigWithFile(path, cfgOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Bind the output flags to the options loaded from the file, so that the
+	// flags that were set can be copied over them.
+	fileOpts := new(output.Options)
+	fileFlags := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	addOutputFlags(fileFlags, fileOpts)
+	if err = cfg.Unpack(fileOpts, cfgOpts...); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	var fc fileConfig
+	if err = cfg.Unpack(&fc, cfgOpts...); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	var setErr error
+	flags.VisitAll(func(f *pflag.Flag) {
+		target := fileFlags.Lookup(f.Name)
+		if target == nil || setErr != nil {
+			return
+		}
+		if !f.Changed && os.Getenv(envVarName(f.Name)) == "" {
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			setErr = target.Value.(pflag.SliceValue).Replace(sv.GetSlice())
+		} else {
+			setErr = target.Value.Set(f.Value.String())
+		}
+		if setErr != nil {
+			setErr = fmt.Errorf("failed to override config with flag --%s: %w", f.Name, setErr)
+		}
+	})
+	if setErr != nil {
+		return nil, setErr
+	}
+
+	*opts = *fileOpts
+	return fc.Fanout, nil
+}
@@ -0,0 +1,89 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("TEST_STREAM_HOST", "intake.example.com")
+	t.Setenv("STREAM_RETRY", "7")
+
+	path := filepath.Join(t.TempDir(), "stream.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+protocol: webhook
+addr: https://${TEST_STREAM_HOST}/events
+retries: 2
+delay: 5s
+rate:
+  events_per_second: 100
+webhook:
+  headers: ["X-Env=${TEST_STREAM_ENV:dev}"]
+  accept_status: [202]
+  timeout: 3s
+fanout:
+  - protocol=udp,addr=127.0.0.1:514
+`), 0o600))
+
+	var opts output.Options
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	addOutputFlags(flags, &opts)
+	require.NoError(t, flags.Parse([]string{"--webhook-header=A=1", "--webhook-header=B=2", "--delay=1s"}))
+	// Environment variables are applied to the flags before parsing.
+	require.NoError(t, flags.Set("retry", "7"))
+	flags.Lookup("retry").Changed = false
+
+	fanout, err := loadConfig(path, flags, &opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"protocol=udp,addr=127.0.0.1:514"}, fanout)
+	assert.Equal(t, "webhook", opts.Protocol)
+	assert.Equal(t, "https://intake.example.com/events", opts.Addr)
+	assert.Equal(t, 100.0, opts.RateLimitOptions.EventsPerSecond)
+	assert.Equal(t, []string{"202"}, opts.WebhookOptions.AcceptStatus)
+	assert.Equal(t, 3*time.Second, opts.WebhookOptions.Timeout)
+
+	// Flags and environment variables take precedence over the file.
+	assert.Equal(t, []string{"A=1", "B=2"}, opts.WebhookOptions.Headers)
+	assert.Equal(t, time.Second, opts.Delay)
+	assert.Equal(t, 7, opts.Retries)
+
+	// Options missing from the file keep their flag defaults.
+	assert.Equal(t, "application/json", opts.WebhookOptions.ContentType)
+	assert.Equal(t, 500*1024, opts.MaxLogLineSize)
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"bad type":        "retries: many",
+		"missing env var": "addr: ${TEST_STREAM_UNSET_VARIABLE}",
+		"not yaml":        "addr: [",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "stream.yml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+			var opts output.Options
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			addOutputFlags(flags, &opts)
+
+			_, err := loadConfig(path, flags, &opts)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := loadConfig(filepath.Join(t.TempDir(), "missing.yml"), pflag.NewFlagSet("test", pflag.ContinueOnError), &output.Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package command
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+// parseFanout parses --fanout entries. Each entry is a comma separated list of
+// flag=value pairs (e.g. protocol=udp,addr=127.0.0.1:514,policy=best-effort)
+// that override the flags in base for that output. Values containing commas
+// must be quoted (e.g. "addr=broker1:9092,broker2:9092").
+func parseFanout(entries []string, base *output.Options) ([]output.FanoutOutput, error) {
+	fanout := make([]output.FanoutOutput, 0, len(entries))
+	for _, entry := range entries {
+		pairs, err := csv.NewReader(strings.NewReader(entry)).Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse fanout %q: %w", entry, err)
+		}
+
+		// Bind the output flags to a copy of base, so that any flag not set in
+		// the entry keeps its value from the command line.
+		opts := new(output.Options)
+		flags := pflag.NewFlagSet("fanout", pflag.ContinueOnError)
+		addOutputFlags(flags, opts)
+		*opts = *base
+		opts.Fanout = nil
+
+		fo := output.FanoutOutput{Options: opts}
+		for _, kv := range pairs {
+			name, value, found := strings.Cut(strings.TrimSpace(kv), "=")
+			if !found {
+				return nil, fmt.Errorf("failed to parse %q in fanout %q as flag=value", kv, entry)
+			}
+			if name == "policy" {
+				fo.Policy = value
+				continue
+			}
+			if err := flags.Set(name, value); err != nil {
+				return nil, fmt.Errorf("invalid fanout %q: %w", entry, err)
+			}
+		}
+		fanout = append(fanout, fo)
+	}
+	return fanout, nil
+}
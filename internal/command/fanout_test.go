@@ -0,0 +1,60 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+func TestParseFanout(t *testing.T) {
+	base := &output.Options{
+		Protocol: "tcp",
+		Addr:     "localhost:9000",
+		Retries:  3,
+		WebhookOptions: output.WebhookOptions{
+			Headers: []string{"A=1"},
+			Timeout: time.Second,
+		},
+	}
+
+	fanout, err := parseFanout([]string{
+		`protocol=udp,addr=127.0.0.1:514,policy=best-effort`,
+		`protocol=kafka,"addr=broker1:9092,broker2:9092",kafka-topic=logs,webhook-header=B=2`,
+	}, base)
+	require.NoError(t, err)
+	require.Len(t, fanout, 2)
+
+	assert.Equal(t, output.PolicyBestEffort, fanout[0].Policy)
+	assert.Equal(t, "udp", fanout[0].Options.Protocol)
+	assert.Equal(t, "127.0.0.1:514", fanout[0].Options.Addr)
+	assert.Equal(t, 3, fanout[0].Options.Retries, "unset flags keep the base value")
+
+	assert.Equal(t, "", fanout[1].Policy)
+	assert.Equal(t, "broker1:9092,broker2:9092", fanout[1].Options.Addr)
+	assert.Equal(t, "logs", fanout[1].Options.KafkaOptions.Topic)
+	assert.Equal(t, []string{"B=2"}, fanout[1].Options.WebhookOptions.Headers)
+	assert.Equal(t, time.Second, fanout[1].Options.WebhookOptions.Timeout)
+
+	// The base options are not modified.
+	assert.Equal(t, []string{"A=1"}, base.WebhookOptions.Headers)
+	assert.Equal(t, "tcp", base.Protocol)
+}
+
+func TestParseFanoutInvalid(t *testing.T) {
+	for _, entry := range []string{
+		`protocol=udp,nope`,
+		`protocol=udp,unknown-flag=1`,
+		`protocol=udp,retry=many`,
+	} {
+		_, err := parseFanout([]string{entry}, &output.Options{})
+		assert.Error(t, err, entry)
+	}
+}
@@ -0,0 +1,83 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/stream/internal/status"
+)
+
+type healthCheckRunner struct {
+	cmd  *cobra.Command
+	opts *status.Options
+}
+
+// newHealthCheckRunner returns a command that queries the health endpoint of
+// another stream process, which lets images without a shell use it as a
+// container healthcheck.
+func newHealthCheckRunner(opts *status.Options) *cobra.Command {
+	r := &healthCheckRunner{
+		opts: opts,
+		cmd: &cobra.Command{
+			Use:   "healthcheck",
+			Short: "Exit with an error unless the --health-addr endpoint reports that stream is ready",
+			Args:  cobra.NoArgs,
+			// Do not start a health endpoint or wait for the start signal.
+			PersistentPreRunE: func(*cobra.Command, []string) error { return nil },
+		},
+	}
+
+	r.cmd.RunE = func(_ *cobra.Command, _ []string) error {
+		return r.Run()
+	}
+
+	return r.cmd
+}
+
+// Run executes the healthcheck command. The status is written to stdout.
+func (r *healthCheckRunner) Run() error {
+	if r.opts.HealthAddr == "" {
+		return errors.New("--health-addr is required")
+	}
+	host, port, err := net.SplitHostPort(r.opts.HealthAddr)
+	if err != nil {
+		return fmt.Errorf("invalid health address: %w", err)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	ctx, cancel := context.WithTimeout(r.cmd.Context(), 5*time.Second)
+	defer cancel()
+
+	url := "http://" + net.JoinHostPort(host, port) + status.HealthPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err = io.Copy(os.Stdout, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("stream is not ready: %s", resp.Status)
+	}
+	return nil
+}
@@ -5,21 +5,26 @@
 package command
 
 import (
+	"errors"
+
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
 	"github.com/elastic/stream/internal/httpserver"
+	"github.com/elastic/stream/internal/status"
 )
 
 type httpServerRunner struct {
 	logger *zap.SugaredLogger
 	cmd    *cobra.Command
 	opts   *httpserver.Options
+	status *status.Tracker
 }
 
-func newHTTPServerRunner(options *httpserver.Options, logger *zap.Logger) *cobra.Command {
+func newHTTPServerRunner(options *httpserver.Options, tracker *status.Tracker, logger *zap.Logger) *cobra.Command {
 	r := &httpServerRunner{
-		opts: options,
+		opts:   options,
+		status: tracker,
 		cmd: &cobra.Command{
 			Use:   "http-server [options]",
 			Short: "Set up a mock http server",
@@ -34,8 +39,13 @@ func newHTTPServerRunner(options *httpserver.Options, logger *zap.Logger) *cobra
 	return r.cmd
 }
 
-// Run executes the http-server command.
-func (r *httpServerRunner) Run() error {
+// Run executes the http-server command. The server is ready once it is
+// listening, and finished once it is shut down.
+func (r *httpServerRunner) Run() (err error) {
+	defer func() {
+		err = errors.Join(err, r.status.Done(err))
+	}()
+
 	r.logger.Debug("mock server running...")
 	server, err := httpserver.New(r.opts, r.logger)
 	if err != nil {
@@ -45,6 +55,9 @@ func (r *httpServerRunner) Run() error {
 	if err := server.Start(r.cmd.Context()); err != nil {
 		return err
 	}
+	if err := r.status.Ready(); err != nil {
+		return errors.Join(err, server.Close())
+	}
 
 	<-r.cmd.Context().Done()
 
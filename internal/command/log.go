@@ -5,7 +5,7 @@
 package command
 
 import (
-	"bufio"
+	"errors"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -13,17 +13,21 @@ import (
 
 	"github.com/elastic/stream/internal/cmdutil"
 	"github.com/elastic/stream/internal/output"
+	"github.com/elastic/stream/internal/status"
+	"github.com/elastic/stream/pkg/send"
 )
 
 type logRunner struct {
 	logger *zap.SugaredLogger
 	cmd    *cobra.Command
 	out    *output.Options
+	status *status.Tracker
 }
 
-func newLogRunner(options *output.Options, logger *zap.Logger) *cobra.Command {
+func newLogRunner(options *output.Options, tracker *status.Tracker, logger *zap.Logger) *cobra.Command {
 	r := &logRunner{
-		out: options,
+		out:    options,
+		status: tracker,
 		cmd: &cobra.Command{
 			Use:   "log [log file to stream]",
 			Short: "Stream log file lines",
@@ -39,13 +43,26 @@ func newLogRunner(options *output.Options, logger *zap.Logger) *cobra.Command {
 	return r.cmd
 }
 
-// Run executes the log command.
-func (r *logRunner) Run(args []string) error {
+// Run executes the log command. Errors from closing the output, such as failed
+// response assertions, are returned. Its progress is reported to the status
+// tracker.
+func (r *logRunner) Run(args []string) (err error) {
+	defer func() {
+		err = errors.Join(err, r.status.Done(err))
+	}()
+
 	out, err := output.Initialize(r.cmd.Context(), r.out, r.logger)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	defer func() {
+		err = errors.Join(err, out.Close())
+	}()
+
+	if err := r.status.Ready(); err != nil {
+		return err
+	}
+	out = r.status.Output(out)
 
 	files, err := cmdutil.ExpandGlobPatternsFromArgs(args)
 	if err != nil {
@@ -62,36 +79,16 @@ func (r *logRunner) Run(args []string) error {
 }
 
 func (r *logRunner) sendLog(path string, out output.Output) error {
-	logger := r.logger.With("log", path)
-
 	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	var totalBytes, totalLines int
-	s := bufio.NewScanner(bufio.NewReader(f))
-	buf := make([]byte, r.out.MaxLogLineSize)
-	s.Buffer(buf, r.out.MaxLogLineSize)
-	for s.Scan() {
-		if r.cmd.Context().Err() != nil {
-			break
-		}
-
-		logger.Debugw("Sending log line.", "line_number", totalLines+1)
-		n, err := out.Write(s.Bytes())
-		if err != nil {
-			return err
-		}
-
-		totalBytes += n
-		totalLines++
-	}
-	if s.Err() != nil {
-		return s.Err()
-	}
-
-	logger.Infow("Log data sent.", "total_bytes", totalBytes, "total_lines", totalLines)
-	return nil
+	_, err = send.Log(r.cmd.Context(), f, out, send.LogOptions{
+		Source:      path,
+		MaxLineSize: r.out.MaxLogLineSize,
+		Logger:      r.logger.With("log", path),
+	})
+	return err
 }
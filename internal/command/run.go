@@ -0,0 +1,57 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package command
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/elastic/stream/internal/cmdutil"
+	"github.com/elastic/stream/internal/output"
+	"github.com/elastic/stream/internal/scenario"
+	"github.com/elastic/stream/internal/status"
+)
+
+type runRunner struct {
+	logger *zap.SugaredLogger
+	cmd    *cobra.Command
+	out    *output.Options
+	status *status.Tracker
+}
+
+func newRunRunner(options *output.Options, tracker *status.Tracker, logger *zap.Logger) *cobra.Command {
+	r := &runRunner{
+		out:    options,
+		status: tracker,
+		cmd: &cobra.Command{
+			Use:   "run [scenario file]",
+			Short: "Run the steps of a scenario file",
+			Args:  cmdutil.ValidateArgs(cobra.ExactArgs(1), cmdutil.RegularFiles),
+		},
+	}
+
+	r.cmd.RunE = func(_ *cobra.Command, args []string) error {
+		r.logger = logger.Sugar().With("scenario", args[0])
+		return r.Run(args[0])
+	}
+
+	return r.cmd
+}
+
+// Run executes the run command. The output flags are the defaults of the log
+// and pcap steps.
+func (r *runRunner) Run(path string) (err error) {
+	defer func() {
+		err = errors.Join(err, r.status.Done(err))
+	}()
+
+	s, err := scenario.Load(path)
+	if err != nil {
+		return err
+	}
+	return s.Run(r.cmd.Context(), r.out, r.status, r.logger)
+}
@@ -5,73 +5,42 @@
 package httpserver
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
-	"text/template"
-	"time"
 
 	ucfg "github.com/elastic/go-ucfg"
 	"github.com/elastic/go-ucfg/yaml"
 )
 
-type config struct {
-	AsSequence bool   `config:"as_sequence"`
-	Rules      []rule `config:"rules"`
+// Config holds the rules of the mock server. It can be loaded from a YAML file
+// or built programmatically.
+type Config struct {
+	AsSequence bool   `config:"as_sequence"` // Fail if requests do not match the rules in order.
+	Rules      []Rule `config:"rules"`       // Rules in matching order. More restrictive rules go first.
 }
 
-type rule struct {
-	Path    string   `config:"path"`
-	Methods []string `config:"methods"`
+// Rule matches requests and defines the responses returned for them.
+type Rule struct {
+	Path    string   `config:"path"`    // Path to match. It can use gorilla/mux patterns.
+	Methods []string `config:"methods"` // Methods to match.
 
-	User           string              `config:"user"`
-	Password       string              `config:"password"`
-	QueryParams    map[string][]string `config:"query_params"`
-	RequestBody    string              `config:"request_body"`
-	RequestHeaders map[string][]string `config:"request_headers"`
+	User           string              `config:"user"`            // Basic auth username to match.
+	Password       string              `config:"password"`        // Basic auth password to match.
+	QueryParams    map[string][]string `config:"query_params"`    // Query parameters to match. An empty list rejects requests with the parameter.
+	RequestBody    string              `config:"request_body"`    // Body to match. A value quoted with slashes is a regular expression.
+	RequestHeaders map[string][]string `config:"request_headers"` // Header regular expressions to match.
 
-	Responses []response `config:"responses"`
+	Responses []Response `config:"responses"` // Responses returned in rolling sequence.
 }
 
-type response struct {
-	Headers    map[string][]*tpl `config:"headers"`
-	Body       *tpl              `config:"body"`
-	StatusCode int               `config:"status_code"`
+// Response is a response returned for a matched request. Headers and Body are
+// Go templates.
+type Response struct {
+	Headers    map[string][]string `config:"headers"`
+	Body       string              `config:"body"`
+	StatusCode int                 `config:"status_code"`
 }
 
-type tpl struct {
-	*template.Template
-}
-
-// Unpack implements the go-ucfg StringUnpacker interface for tpl. It parses the
-// provided string as a Go template, registering custom helper functions for use
-// within the template, and assigns the resulting template to the receiver.
-func (t *tpl) Unpack(in string) error {
-	parsed, err := template.New("").
-		Option("missingkey=zero").
-		Funcs(template.FuncMap{
-			"env":         env,
-			"hostname":    hostname,
-			"sum":         sum,
-			"file":        file,
-			"glob":        filepath.Glob,
-			"minify_json": minify,
-			"now":         now,
-		}).
-		Parse(in)
-	if err != nil {
-		return err
-	}
-
-	*t = tpl{Template: parsed}
-
-	return nil
-}
-
-func newConfigFromFile(file string) (*config, error) {
+func newConfigFromFile(file string) (*Config, error) {
 	if file == "" {
 		return nil, errors.New("a rules config file is required")
 	}
@@ -81,55 +50,10 @@ func newConfigFromFile(file string) (*config, error) {
 		return nil, err
 	}
 
-	var config config
+	var config Config
 	if err := cfg.Unpack(&config); err != nil {
 		return nil, err
 	}
 
 	return &config, nil
 }
-
-func env(key string) string {
-	return os.Getenv(key)
-}
-
-func hostname() string {
-	h, _ := os.Hostname()
-	return h
-}
-
-func sum(a, b int) int {
-	return a + b
-}
-
-func file(path string) (string, error) {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return "", err
-	}
-	return string(b), nil
-}
-
-func minify(body string) (string, error) {
-	var buf strings.Builder
-	enc := json.NewEncoder(&buf)
-	enc.SetEscapeHTML(false)
-	err := enc.Encode(json.RawMessage(body))
-	return strings.TrimSpace(buf.String()), err
-}
-
-// now returns the current UTC time. An optional Go duration string
-// offsets the result (e.g. "-720h" for 30 days ago). The returned
-// time.Time value exposes its methods to templates, so callers can
-// format it as needed: {{ (now).Format "2006-01-02" }}.
-func now(offset ...string) (time.Time, error) {
-	t := time.Now().UTC()
-	if len(offset) == 0 {
-		return t, nil
-	}
-	d, err := time.ParseDuration(offset[0])
-	if err != nil {
-		return time.Time{}, fmt.Errorf("invalid duration %q: %w", offset[0], err)
-	}
-	return t.Add(d), nil
-}
@@ -20,6 +20,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/gorilla/handlers"
@@ -28,6 +29,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/elastic/stream/internal/output"
+	"github.com/elastic/stream/internal/tmpl"
 )
 
 // Server is an HTTP server for mocking HTTP responses.
@@ -46,7 +48,9 @@ type Options struct {
 	TLSKey              string        // TLS key file path.
 	ReadTimeout         time.Duration // HTTP Server read timeout.
 	WriteTimeout        time.Duration // HTTP Server write timeout.
-	ConfigPath          string        // Config path.
+	ConfigPath          string        // Config path. Ignored if Config is set.
+	Config              *Config       // Rules config. If nil, it is loaded from ConfigPath.
+	AccessLog           io.Writer     // Access log destination. Defaults to stdout.
 	DelayParticipation  float32       // Delay participation rate (fraction of requests that will be delayed. 0.0 <= p <= 1.0).
 	DelayDuration       time.Duration // Delay duration.
 	FaultParticipation  float32       // Fault participation rate (fraction of requests that will fail. 0.0 <= p <= 1.0).
@@ -56,7 +60,7 @@ type Options struct {
 
 // New creates a new HTTP server.
 func New(opts *Options, logger *zap.SugaredLogger) (*Server, error) {
-	if opts.Addr == "" {
+	if opts.Options == nil || opts.Addr == "" {
 		return nil, errors.New("a listen address is required")
 	}
 
@@ -66,9 +70,12 @@ func New(opts *Options, logger *zap.SugaredLogger) (*Server, error) {
 		return nil, errors.New("both TLS certificate and key files must be defined")
 	}
 
-	config, err := newConfigFromFile(opts.ConfigPath)
-	if err != nil {
-		return nil, err
+	config := opts.Config
+	if config == nil {
+		var err error
+		if config, err = newConfigFromFile(opts.ConfigPath); err != nil {
+			return nil, err
+		}
 	}
 
 	notFoundHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -114,8 +121,12 @@ func New(opts *Options, logger *zap.SugaredLogger) (*Server, error) {
 		handler = f.Handler(handler)
 	}
 
-	// Log all request/responses to stdout.
-	handler = handlers.CombinedLoggingHandler(os.Stdout, handler)
+	// Log all request/responses to stdout by default.
+	accessLog := opts.AccessLog
+	if accessLog == nil {
+		accessLog = os.Stdout
+	}
+	handler = handlers.CombinedLoggingHandler(accessLog, handler)
 
 	server := &http.Server{
 		ReadTimeout:    opts.ReadTimeout,
@@ -155,6 +166,15 @@ func (o *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// Addr returns the address the server is listening on. It is nil until the
+// server is started.
+func (o *Server) Addr() net.Addr {
+	if o.listener == nil {
+		return nil
+	}
+	return o.listener.Addr()
+}
+
 // Close gracefully shuts down the server without interrupting any
 // active connections.
 func (o *Server) Close() error {
@@ -166,7 +186,36 @@ func (o *Server) Close() error {
 	return o.server.Shutdown(ctx)
 }
 
-func newHandlerFromConfig(config *config, notFoundHandler http.HandlerFunc, logger *zap.SugaredLogger) (http.Handler, error) {
+// response is a Response with parsed templates.
+type response struct {
+	headers    map[string][]*template.Template
+	body       *template.Template
+	statusCode int
+}
+
+func newResponse(r Response) (*response, error) {
+	body, err := tmpl.Parse("", r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse body template: %w", err)
+	}
+	resp := &response{
+		headers:    make(map[string][]*template.Template, len(r.Headers)),
+		body:       body,
+		statusCode: r.StatusCode,
+	}
+	for k, values := range r.Headers {
+		for _, v := range values {
+			t, err := tmpl.Parse("", v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse header template %s: %w", k, err)
+			}
+			resp.headers[k] = append(resp.headers[k], t)
+		}
+	}
+	return resp, nil
+}
+
+func newHandlerFromConfig(config *Config, notFoundHandler http.HandlerFunc, logger *zap.SugaredLogger) (http.Handler, error) {
 	router := mux.NewRouter()
 
 	var buf bytes.Buffer
@@ -181,6 +230,16 @@ func newHandlerFromConfig(config *config, notFoundHandler http.HandlerFunc, logg
 			posInSeq += len(config.Rules[i-1].Responses)
 		}
 		posInSeq := posInSeq
+
+		responses := make([]*response, 0, len(rule.Responses))
+		for j, r := range rule.Responses {
+			resp, err := newResponse(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid response #%d of rule #%d: %w", j, i, err)
+			}
+			responses = append(responses, resp)
+		}
+
 		logger.Debugf("Setting up rule #%d for path %q", i, rule.Path)
 		route := router.HandleFunc(rule.Path, func(w http.ResponseWriter, r *http.Request) {
 			isNext := currInSeq == posInSeq+count
@@ -189,13 +248,13 @@ func newHandlerFromConfig(config *config, notFoundHandler http.HandlerFunc, logg
 			}
 
 			response := func() *response {
-				switch len(rule.Responses) {
+				switch len(responses) {
 				case 0:
 					return nil
 				case 1:
-					return &rule.Responses[0]
+					return responses[0]
 				}
-				return &rule.Responses[count%len(rule.Responses)]
+				return responses[count%len(responses)]
 			}()
 
 			count++
@@ -214,7 +273,7 @@ func newHandlerFromConfig(config *config, notFoundHandler http.HandlerFunc, logg
 			}
 
 			if response != nil {
-				for k, tpls := range response.Headers {
+				for k, tpls := range response.headers {
 					for _, tpl := range tpls {
 						buf.Reset()
 						if err := tpl.Execute(&buf, data); err != nil {
@@ -225,10 +284,10 @@ func newHandlerFromConfig(config *config, notFoundHandler http.HandlerFunc, logg
 					}
 				}
 
-				w.WriteHeader(response.StatusCode)
+				w.WriteHeader(response.statusCode)
 
-				if err := response.Body.Execute(w, data); err != nil {
-					logger.Errorf("executing body template %s: %v", response.Body.Root.String(), err)
+				if err := response.body.Execute(w, data); err != nil {
+					logger.Errorf("executing body template %s: %v", response.body.Root.String(), err)
 				}
 			}
 		})
@@ -297,48 +297,6 @@ func TestHTTPServer(t *testing.T) {
 	})
 }
 
-func TestNow(t *testing.T) {
-	t.Run("no offset", func(t *testing.T) {
-		before := time.Now().UTC()
-		got, err := now()
-		if err != nil {
-			t.Fatalf("now() error: %v", err)
-		}
-		if got.Before(before.Add(-time.Second)) || got.After(time.Now().UTC().Add(time.Second)) {
-			t.Errorf("now() = %s; want within 1s of current time", got)
-		}
-	})
-
-	t.Run("negative offset", func(t *testing.T) {
-		before := time.Now().UTC().Add(-24 * time.Hour)
-		got, err := now("-24h")
-		if err != nil {
-			t.Fatalf("now(%q) error: %v", "-24h", err)
-		}
-		if got.Before(before.Add(-time.Second)) || got.After(before.Add(time.Second)) {
-			t.Errorf("now(%q) = %s; want within 1s of %s", "-24h", got, before)
-		}
-	})
-
-	t.Run("positive offset", func(t *testing.T) {
-		expected := time.Now().UTC().Add(2 * time.Hour)
-		got, err := now("2h")
-		if err != nil {
-			t.Fatalf("now(%q) error: %v", "2h", err)
-		}
-		if got.Before(expected.Add(-time.Second)) || got.After(expected.Add(time.Second)) {
-			t.Errorf("now(%q) = %s; want within 1s of %s", "2h", got, expected)
-		}
-	})
-
-	t.Run("invalid offset", func(t *testing.T) {
-		_, err := now("bogus")
-		if err == nil {
-			t.Error("now(\"bogus\") error = nil; want error")
-		}
-	})
-}
-
 func TestRunAsSequence(t *testing.T) {
 	cfg := `---
   as_sequence: true
@@ -553,3 +511,51 @@ func startTestServer(t *testing.T, opts *Options, logger *zap.SugaredLogger) (*S
 
 	return server, addr
 }
+
+func TestProgrammaticConfig(t *testing.T) {
+	var accessLog bytes.Buffer
+	opts := Options{
+		Options: &output.Options{
+			Addr: "localhost:0",
+		},
+		Config: &Config{
+			Rules: []Rule{
+				{
+					Path:    "/items/{id}",
+					Methods: []string{"GET"},
+					Responses: []Response{
+						{
+							StatusCode: 200,
+							Headers:    map[string][]string{"x-req": {"{{ .req_num }}"}},
+							Body:       `{"id": "{{ .request.vars.id }}"}`,
+						},
+					},
+				},
+			},
+		},
+		AccessLog: &accessLog,
+	}
+
+	server, _ := startTestServer(t, &opts, zap.NewNop().Sugar())
+
+	resp, err := http.Get("http://" + server.Addr().String() + "/items/42")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("x-req"))
+	assert.JSONEq(t, `{"id": "42"}`, string(body))
+	assert.Contains(t, accessLog.String(), "GET /items/42")
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	_, err := New(&Options{
+		Options: &output.Options{Addr: "localhost:0"},
+		Config: &Config{
+			Rules: []Rule{{Path: "/", Responses: []Response{{Body: "{{ .unclosed"}}}},
+		},
+	}, zap.NewNop().Sugar())
+	assert.ErrorContains(t, err, "invalid response #0 of rule #0")
+}
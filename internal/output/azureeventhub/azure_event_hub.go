@@ -12,6 +12,7 @@ package azureeventhub
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
@@ -90,3 +91,37 @@ func (o *Output) Write(b []byte) (int, error) {
 
 	return len(b), nil
 }
+
+// WriteBatch implements output.BatchOutput. Events are added to an event data
+// batch, which is sent whenever it can't hold the next event.
+func (o *Output) WriteBatch(events [][]byte) error {
+	batch, err := o.producerClient.NewEventDataBatch(o.cancelCtx, nil)
+	if err != nil {
+		return fmt.Errorf("error while creating new event data batch: %w", err)
+	}
+
+	for _, b := range events {
+		eventData := &azeventhubs.EventData{Body: b}
+		err := batch.AddEventData(eventData, nil)
+		if errors.Is(err, azeventhubs.ErrEventDataTooLarge) && batch.NumEvents() > 0 {
+			if err := o.producerClient.SendEventDataBatch(o.cancelCtx, batch, nil); err != nil {
+				return fmt.Errorf("error while sending event data batch: %w", err)
+			}
+			if batch, err = o.producerClient.NewEventDataBatch(o.cancelCtx, nil); err != nil {
+				return fmt.Errorf("error while creating new event data batch: %w", err)
+			}
+			err = batch.AddEventData(eventData, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("error while adding data to event data batch: %w", err)
+		}
+	}
+
+	if batch.NumEvents() == 0 {
+		return nil
+	}
+	if err := o.producerClient.SendEventDataBatch(o.cancelCtx, batch, nil); err != nil {
+		return fmt.Errorf("error while sending event data batch: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+)
+
+// BatchOptions holds configuration for batching events written to outputs
+// that implement BatchOutput.
+type BatchOptions struct {
+	Count    int           `config:"count"`    // Flush after this many events. Zero or one disables batching by count.
+	Bytes    int           `config:"bytes"`    // Flush after this many bytes. Zero disables batching by size.
+	Interval time.Duration `config:"interval"` // Flush a partial batch after this long. Zero waits for a full batch.
+}
+
+// Enabled returns true if events should be batched. An interval alone batches
+// the events written during each interval.
+func (o BatchOptions) Enabled() bool {
+	return o.Count > 1 || o.Bytes > 0 || o.Interval > 0
+}
+
+// batcher is an Output that buffers events and writes them to a BatchOutput
+// when the batch is full or the flush interval has elapsed.
+type batcher struct {
+	BatchOutput
+
+	opts BatchOptions
+
+	mu     sync.Mutex // Guards the batch and serializes flushes.
+	batch  [][]byte
+	bytes  int
+	err    error // First error from a background flush.
+	failed int   // Events in batches that failed to be written.
+
+	once    sync.Once
+	done    chan struct{} // Closed to stop the flush loop.
+	stopped chan struct{} // Closed when the flush loop has returned.
+}
+
+func newBatcher(out BatchOutput, opts BatchOptions) *batcher {
+	return &batcher{BatchOutput: out, opts: opts}
+}
+
+// Write adds a copy of b to the batch, and writes the batch if it is full.
+func (b *batcher) Write(data []byte) (int, error) {
+	if b.opts.Interval > 0 {
+		b.once.Do(func() {
+			b.done = make(chan struct{})
+			b.stopped = make(chan struct{})
+			go b.flushLoop()
+		})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		b.failed++
+		return 0, b.err
+	}
+
+	// The caller may reuse data, and the output may keep the events.
+	b.batch = append(b.batch, bytes.Clone(data))
+	b.bytes += len(data)
+
+	if (b.opts.Count > 0 && len(b.batch) >= b.opts.Count) || (b.opts.Bytes > 0 && b.bytes >= b.opts.Bytes) {
+		if err := b.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// StartSource writes the batch and forwards path to the wrapped output, so
+// events are not mixed across sources.
+func (b *batcher) StartSource(path string) error {
+	b.mu.Lock()
+	err := b.flush()
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return StartSource(b.BatchOutput, path)
+}
+
+// Close writes any buffered events and closes the wrapped output.
+func (b *batcher) Close() error {
+	if b.done != nil {
+		close(b.done)
+		<-b.stopped
+	}
+
+	b.mu.Lock()
+	err := errors.Join(b.err, b.flush())
+	b.mu.Unlock()
+
+	return errors.Join(err, b.BatchOutput.Close())
+}
+
+// flush writes the batch. The caller must hold b.mu.
+func (b *batcher) flush() error {
+	if len(b.batch) == 0 {
+		return nil
+	}
+
+	batch := b.batch
+	b.batch, b.bytes = nil, 0
+	if err := b.BatchOutput.WriteBatch(batch); err != nil {
+		b.failed += len(batch)
+		return err
+	}
+	return nil
+}
+
+// FailedEvents implements BufferedOutput.
+func (b *batcher) FailedEvents() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failed
+}
+
+// flushLoop periodically writes partial batches.
+func (b *batcher) flushLoop() {
+	defer close(b.stopped)
+
+	ticker := time.NewTicker(b.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			if err := b.flush(); err != nil && b.err == nil {
+				b.err = err
+			}
+			b.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// batchRecordingOutput records the batches written to it.
+type batchRecordingOutput struct {
+	recordingOutput
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (o *batchRecordingOutput) WriteBatch(batch [][]byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return o.err
+	}
+	var events []string
+	for _, b := range batch {
+		events = append(events, string(b))
+	}
+	o.batches = append(o.batches, events)
+	return nil
+}
+
+func (o *batchRecordingOutput) get() [][]string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.batches
+}
+
+func TestBatcherCount(t *testing.T) {
+	out := &batchRecordingOutput{}
+	b := newBatcher(out, BatchOptions{Count: 2})
+
+	buf := make([]byte, 1)
+	for _, c := range "abcde" {
+		// Reusing the buffer must not change buffered events.
+		buf[0] = byte(c)
+		_, err := b.Write(buf)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}}, out.get())
+
+	// Close writes the partial batch.
+	require.NoError(t, b.Close())
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, out.get())
+	assert.True(t, out.closed)
+	assert.Empty(t, out.events, "events must not be written one at a time")
+}
+
+func TestBatcherBytes(t *testing.T) {
+	out := &batchRecordingOutput{}
+	b := newBatcher(out, BatchOptions{Bytes: 5})
+
+	for _, event := range []string{"abc", "de", "f"} {
+		_, err := b.Write([]byte(event))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, [][]string{{"abc", "de"}}, out.get())
+	require.NoError(t, b.Close())
+}
+
+func TestBatcherInterval(t *testing.T) {
+	out := &batchRecordingOutput{}
+	b := newBatcher(out, BatchOptions{Count: 100, Interval: 10 * time.Millisecond})
+	defer b.Close()
+
+	_, err := b.Write([]byte("a"))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(out.get()) == 1 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBatcherIntervalOnly(t *testing.T) {
+	opts := BatchOptions{Interval: 10 * time.Millisecond}
+	require.True(t, opts.Enabled())
+
+	out := &batchRecordingOutput{}
+	b := newBatcher(out, opts)
+	defer b.Close()
+
+	for _, event := range []string{"a", "b"} {
+		_, err := b.Write([]byte(event))
+		require.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool { return len(out.get()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, [][]string{{"a", "b"}}, out.get())
+}
+
+func TestBatcherStartSource(t *testing.T) {
+	out := &batchRecordingOutput{}
+	b := newBatcher(out, BatchOptions{Count: 100})
+
+	_, err := b.Write([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, StartSource(b, "b.log"))
+
+	assert.Equal(t, [][]string{{"a"}}, out.get())
+	assert.Equal(t, []string{"b.log"}, out.sources)
+	require.NoError(t, b.Close())
+}
+
+func TestBatcherError(t *testing.T) {
+	out := &batchRecordingOutput{err: errors.New("failed")}
+	b := newBatcher(out, BatchOptions{Count: 1, Bytes: 1})
+
+	_, err := b.Write([]byte("a"))
+	assert.Error(t, err)
+}
+
+func TestInitializeBatching(t *testing.T) {
+	var out *batchRecordingOutput
+	Register("batch-test", func(*Options) (Output, error) {
+		out = &batchRecordingOutput{}
+		return out, nil
+	})
+	t.Cleanup(func() { delete(registry, "batch-test") })
+	registerRecordingOutput(t, "")
+
+	opts := &Options{Protocol: "batch-test", Retries: 1, BatchOptions: BatchOptions{Count: 2}}
+	o, err := Initialize(context.Background(), opts, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	assert.IsType(t, &batcher{}, o)
+	require.NoError(t, o.Close())
+
+	// Outputs that don't implement BatchOutput are not batched.
+	opts.Protocol = "recording-test"
+	o, err = Initialize(context.Background(), opts, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	assert.IsType(t, &recordingOutput{}, o)
+	require.NoError(t, o.Close())
+}
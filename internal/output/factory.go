@@ -30,6 +30,61 @@ type Output interface {
 	io.WriteCloser
 }
 
+// SourceOutput is an Output that needs to know which input file the data it
+// writes was read from.
+type SourceOutput interface {
+	Output
+	// StartSource is called before the data read from the input file at path
+	// is written.
+	StartSource(path string) error
+}
+
+// BatchOutput is an Output that can write several events at once, using the
+// native batch API of its destination.
+type BatchOutput interface {
+	Output
+	// WriteBatch writes the events in batch. The output may keep references
+	// to the events, so the caller must not modify them afterwards.
+	WriteBatch(batch [][]byte) error
+}
+
+// BufferedOutput is an Output that buffers events, so a failed write can also
+// lose the events of earlier writes.
+type BufferedOutput interface {
+	Output
+	// FailedEvents returns the number of events that could not be written,
+	// including buffered events that were dropped after a failed write.
+	FailedEvents() int
+}
+
+// Cloner is an Output with state, such as counters, that must be shared by
+// the outputs created for the same options by workers and reconnects.
+type Cloner interface {
+	Output
+	// Clone returns a new output, not yet dialed, that shares the state of
+	// this one.
+	Clone() (Output, error)
+}
+
+// FailedEvents returns the number of events lost by the failed writes to out.
+// It is one for outputs that are not a BufferedOutput, which only lose the
+// event whose write failed.
+func FailedEvents(out Output) int {
+	if bo, ok := out.(BufferedOutput); ok {
+		return max(bo.FailedEvents(), 1)
+	}
+	return 1
+}
+
+// StartSource notifies out that the data read from the input file at path is
+// about to be written. It is a no-op for outputs that are not a SourceOutput.
+func StartSource(out Output, path string) error {
+	if so, ok := out.(SourceOutput); ok {
+		return so.StartSource(path)
+	}
+	return nil
+}
+
 // Register registers a new output factory for a given protocol.
 // This is not thread-safe and should only be called from init() functions.
 func Register(protocol string, factory Factory) {
@@ -0,0 +1,133 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Fan-out error policies.
+const (
+	PolicyFailFast   = "fail-fast"   // An error stops the run.
+	PolicyBestEffort = "best-effort" // Errors are logged and the output is skipped.
+)
+
+// FanoutOutput configures one of the outputs that events are fanned out to.
+type FanoutOutput struct {
+	Options *Options // Options of the output.
+	Policy  string   // Error policy (fail-fast or best-effort).
+}
+
+// fanout is an Output that writes every event to several outputs.
+type fanout struct {
+	targets []*fanoutTarget
+	logger  *zap.SugaredLogger
+}
+
+type fanoutTarget struct {
+	out        Output
+	bestEffort bool
+	logger     *zap.SugaredLogger
+	failures   int // Failed writes of a best-effort output.
+}
+
+func newFanout(ctx context.Context, opts *Options, logger *zap.SugaredLogger) (*fanout, error) {
+	f := &fanout{logger: logger}
+	for i, fo := range opts.Fanout {
+		t := &fanoutTarget{
+			logger: logger.With("fanout", i, "protocol", fo.Options.Protocol, "address", fo.Options.Addr),
+		}
+		switch fo.Policy {
+		case "", PolicyFailFast:
+		case PolicyBestEffort:
+			t.bestEffort = true
+		default:
+			return nil, errors.Join(
+				fmt.Errorf("unknown fanout policy %q (use %s or %s)", fo.Policy, PolicyFailFast, PolicyBestEffort),
+				f.Close(),
+			)
+		}
+
+		out, err := Initialize(ctx, fo.Options, t.logger)
+		if err != nil {
+			if t.bestEffort {
+				t.logger.Warnw("Skipping best-effort output that failed to connect", "error", err)
+				continue
+			}
+			return nil, errors.Join(fmt.Errorf("failed to initialize fanout output %d: %w", i, err), f.Close())
+		}
+		t.out = out
+		f.targets = append(f.targets, t)
+	}
+
+	if len(f.targets) == 0 {
+		return nil, errors.New("no fanout output could be initialized")
+	}
+	return f, nil
+}
+
+// DialContext is a no-op because the outputs are connected when the fan-out is
+// created.
+func (*fanout) DialContext(context.Context) error {
+	return nil
+}
+
+// Write writes b to every output.
+func (f *fanout) Write(b []byte) (int, error) {
+	for _, t := range f.targets {
+		if _, err := t.out.Write(b); err != nil {
+			if err = t.handle(err); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(b), nil
+}
+
+// StartSource forwards path to every output.
+func (f *fanout) StartSource(path string) error {
+	for _, t := range f.targets {
+		if err := StartSource(t.out, path); err != nil {
+			if err = t.handle(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes every output. Errors from best-effort outputs are logged.
+func (f *fanout) Close() error {
+	var errs error
+	for _, t := range f.targets {
+		err := t.out.Close()
+		if t.bestEffort {
+			if err != nil {
+				t.logger.Warnw("Best-effort output failed to close", "error", err)
+			}
+			if t.failures > 0 {
+				t.logger.Warnw("Best-effort output had write failures", "failures", t.failures)
+			}
+			continue
+		}
+		errs = errors.Join(errs, err)
+	}
+	return errs
+}
+
+// handle returns err for fail-fast outputs. For best-effort outputs the error
+// is logged and counted.
+func (t *fanoutTarget) handle(err error) error {
+	if !t.bestEffort {
+		return err
+	}
+	t.failures++
+	t.logger.Debugw("Best-effort output failed", "error", err)
+	return nil
+}
@@ -0,0 +1,96 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func registerFlakyOutput(t *testing.T, server *flakyServer) {
+	t.Helper()
+	Register("flaky-test", func(*Options) (Output, error) {
+		return &flakyOutput{server: server}, nil
+	})
+	t.Cleanup(func() { delete(registry, "flaky-test") })
+}
+
+func TestFanout(t *testing.T) {
+	outputs := registerRecordingOutput(t, "")
+	flaky := &flakyServer{}
+	registerFlakyOutput(t, flaky)
+
+	out, err := Initialize(context.Background(), &Options{
+		Fanout: []FanoutOutput{
+			{Options: &Options{Protocol: "recording-test", Retries: 1}},
+			{Options: &Options{Protocol: "recording-test", Retries: 1, WorkerOptions: WorkerOptions{Workers: 2}}},
+			{Options: &Options{Protocol: "flaky-test", Retries: 1}, Policy: PolicyBestEffort},
+		},
+	}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	require.NoError(t, StartSource(out, "a.log"))
+	_, err = out.Write([]byte("1"))
+	require.NoError(t, err)
+
+	// Failures of best-effort outputs are ignored.
+	flaky.failWrites = 1
+	_, err = out.Write([]byte("2"))
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	require.Len(t, *outputs, 3)
+	assert.Equal(t, []string{"1", "2"}, (*outputs)[0].events)
+	assert.Equal(t, []string{"1"}, (*outputs)[1].events)
+	assert.Equal(t, []string{"2"}, (*outputs)[2].events)
+	assert.Equal(t, []string{"1"}, flaky.events)
+	assert.Equal(t, []string{"a.log"}, flaky.sourcePaths)
+}
+
+func TestFanoutFailFast(t *testing.T) {
+	registerRecordingOutput(t, "")
+	flaky := &flakyServer{}
+	registerFlakyOutput(t, flaky)
+
+	out, err := Initialize(context.Background(), &Options{
+		Fanout: []FanoutOutput{
+			{Options: &Options{Protocol: "recording-test", Retries: 1}},
+			{Options: &Options{Protocol: "flaky-test", Retries: 1}, Policy: PolicyFailFast},
+		},
+	}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	defer out.Close()
+
+	flaky.failWrites = 1
+	_, err = out.Write([]byte("1"))
+	assert.Error(t, err)
+}
+
+func TestFanoutConnectFailure(t *testing.T) {
+	outputs := registerRecordingOutput(t, "")
+	registerFlakyOutput(t, &flakyServer{failDials: 1})
+
+	fanout := []FanoutOutput{
+		{Options: &Options{Protocol: "recording-test", Retries: 1}},
+		{Options: &Options{Protocol: "flaky-test", Retries: 1}, Policy: PolicyBestEffort},
+	}
+
+	// Best-effort outputs that fail to connect are skipped.
+	out, err := Initialize(context.Background(), &Options{Fanout: fanout}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	// Fail-fast outputs that fail to connect stop the run, and the outputs
+	// that were already connected are closed.
+	registerFlakyOutput(t, &flakyServer{failDials: 1})
+	fanout[1].Policy = PolicyFailFast
+	_, err = Initialize(context.Background(), &Options{Fanout: fanout}, zap.NewNop().Sugar())
+	assert.Error(t, err)
+	assert.True(t, (*outputs)[1].closed)
+}
@@ -0,0 +1,66 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSONField returns the value found at the dotted path (e.g. "user.id") in the
+// JSON object contained in b. Strings are returned as-is and any other value is
+// returned in its JSON encoding. The second return value is false if b is not
+// a JSON object or if the path does not exist.
+func JSONField(b []byte, path string) (string, bool) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", false
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if v, ok = m[key]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", false
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(raw), true
+	}
+}
+
+// SplitKeyValues parses a list of Key=Value pairs into a map.
+func SplitKeyValues(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	m := make(map[string]string, len(pairs))
+	for _, kv := range pairs {
+		k, v, found := strings.Cut(kv, "=")
+		if !found || k == "" {
+			return nil, fmt.Errorf("failed to parse %q as Key=Value", kv)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,53 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONField(t *testing.T) {
+	const event = `{"user": {"id": "u1", "age": 42, "roles": ["a", "b"]}, "empty": null}`
+
+	testCases := []struct {
+		path  string
+		value string
+		found bool
+	}{
+		{path: "user.id", value: "u1", found: true},
+		{path: "user.age", value: "42", found: true},
+		{path: "user.roles", value: `["a","b"]`, found: true},
+		{path: "user", value: `{"age":42,"id":"u1","roles":["a","b"]}`, found: true},
+		{path: "user.missing", found: false},
+		{path: "user.id.nested", found: false},
+		{path: "empty", found: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			value, found := JSONField([]byte(event), tc.path)
+			assert.Equal(t, tc.found, found)
+			assert.Equal(t, tc.value, value)
+		})
+	}
+
+	_, found := JSONField([]byte("not json"), "user.id")
+	assert.False(t, found)
+}
+
+func TestSplitKeyValues(t *testing.T) {
+	m, err := SplitKeyValues([]string{"a=1", "b=x=y", "c="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "x=y", "c": ""}, m)
+
+	_, err = SplitKeyValues([]string{"novalue"})
+	assert.Error(t, err)
+
+	_, err = SplitKeyValues([]string{"=value"})
+	assert.Error(t, err)
+}
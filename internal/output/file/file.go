@@ -0,0 +1,72 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package file provides an output that writes events to a local file, one
+// event per line. It is mostly useful as an audit copy of what was sent to
+// another output.
+package file
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+func init() {
+	output.Register("file", New)
+}
+
+// Output is a file output.
+type Output struct {
+	opts *output.Options
+	f    *os.File
+	w    *bufio.Writer
+}
+
+// New returns a new file output. The address is the path of the file.
+func New(opts *output.Options) (output.Output, error) {
+	if opts.Addr == "" {
+		return nil, errors.New("file output requires a path as address")
+	}
+	return &Output{opts: opts}, nil
+}
+
+// DialContext creates the file, truncating it if it exists.
+func (o *Output) DialContext(_ context.Context) error {
+	f, err := os.Create(o.opts.Addr)
+	if err != nil {
+		return err
+	}
+	o.f = f
+	o.w = bufio.NewWriter(f)
+	return nil
+}
+
+// Write writes b followed by a newline.
+func (o *Output) Write(b []byte) (int, error) {
+	if o.f == nil {
+		return 0, errors.New("not connected")
+	}
+
+	if _, err := o.w.Write(b); err != nil {
+		return 0, err
+	}
+	if err := o.w.WriteByte('\n'); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close flushes buffered data and closes the file.
+func (o *Output) Close() error {
+	if o.f == nil {
+		return nil
+	}
+	err := errors.Join(o.w.Flush(), o.f.Close())
+	o.f = nil
+	return err
+}
@@ -0,0 +1,41 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+
+	out, err := New(&output.Options{Addr: path})
+	require.NoError(t, err)
+	require.NoError(t, out.DialContext(context.Background()))
+
+	for _, line := range []string{`{"a":1}`, `{"b":2}`} {
+		n, err := out.Write([]byte(line))
+		require.NoError(t, err)
+		assert.Equal(t, len(line), n)
+	}
+	require.NoError(t, out.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":1}\n{\"b\":2}\n", string(data))
+}
+
+func TestFileRequiresPath(t *testing.T) {
+	_, err := New(&output.Options{})
+	assert.Error(t, err)
+}
@@ -10,12 +10,14 @@
 package gcppubsub
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"sync"
 
 	"cloud.google.com/go/pubsub"
 	"google.golang.org/api/iterator"
@@ -23,6 +25,10 @@ import (
 	"github.com/elastic/stream/internal/output"
 )
 
+// maxPendingResults is the number of asynchronous publish results that may be
+// waiting to be collected before writes block.
+const maxPendingResults = 1000
+
 func init() {
 	output.Register("gcppubsub", New)
 }
@@ -31,16 +37,40 @@ func init() {
 type Output struct {
 	opts       *output.Options
 	client     *pubsub.Client
+	topic      *pubsub.Topic
+	attributes map[string]string
+	fields     map[string]string // Attribute name to JSON field path.
 	cancelFunc func()
+
+	// Pending asynchronous publish results, collected by collectResults.
+	results  chan publishResult
+	done     chan struct{}
+	mu       sync.Mutex
+	asyncErr error
+}
+
+// publishResult is the result of publishing a message with an ordering key.
+type publishResult struct {
+	*pubsub.PublishResult
+	key string
 }
 
 // New returns a new GCP Pub/Sub output.
 func New(opts *output.Options) (output.Output, error) {
-	if opts.Addr == "" {
-		return nil, errors.New("emulator address is required")
+	// Without an address the client connects to Google Cloud using
+	// application default credentials.
+	if opts.Addr != "" {
+		os.Setenv("PUBSUB_EMULATOR_HOST", opts.Addr)
 	}
 
-	os.Setenv("PUBSUB_EMULATOR_HOST", opts.Addr)
+	attributes, err := output.SplitKeyValues(opts.GCPPubsubOptions.Attributes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attribute: %w", err)
+	}
+	fields, err := output.SplitKeyValues(opts.GCPPubsubOptions.AttributeFields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attribute field: %w", err)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	client, err := pubsub.NewClient(ctx, opts.GCPPubsubOptions.Project)
@@ -49,11 +79,65 @@ func New(opts *output.Options) (output.Output, error) {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 
-	return &Output{opts: opts, client: client, cancelFunc: cancel}, nil
+	return &Output{
+		opts:       opts,
+		client:     client,
+		attributes: attributes,
+		fields:     fields,
+		cancelFunc: cancel,
+	}, nil
 }
 
 // DialContext connects to the configured endpoint.
 func (o *Output) DialContext(ctx context.Context) error {
+	if o.opts.Addr != "" {
+		if err := o.probeEmulator(ctx); err != nil {
+			return err
+		}
+
+		// Clearing is limited to the emulator to avoid deleting every topic
+		// and subscription in a real project.
+		if o.opts.GCPPubsubOptions.Clear {
+			if err := o.clear(ctx); err != nil {
+				return err
+			}
+		}
+
+		// The topic and subscription are only created in the emulator. In a
+		// real project they are expected to exist already.
+		if err := o.createTopic(ctx); err != nil {
+			return err
+		}
+
+		if err := o.createSubscription(ctx); err != nil {
+			return err
+		}
+	}
+
+	topic := o.client.Topic(o.opts.GCPPubsubOptions.Topic)
+	topic.EnableMessageOrdering = o.ordered()
+	if n := o.opts.GCPPubsubOptions.BatchCount; n > 0 {
+		topic.PublishSettings.CountThreshold = n
+	}
+	if n := o.opts.GCPPubsubOptions.BatchBytes; n > 0 {
+		topic.PublishSettings.ByteThreshold = n
+	}
+	if d := o.opts.GCPPubsubOptions.BatchDelay; d > 0 {
+		topic.PublishSettings.DelayThreshold = d
+	}
+	o.topic = topic
+
+	if o.opts.GCPPubsubOptions.Async && o.results == nil {
+		o.results = make(chan publishResult, maxPendingResults)
+		o.done = make(chan struct{})
+		go o.collectResults()
+	}
+
+	return nil
+}
+
+// probeEmulator sanity checks that the emulator is up.
+func (o *Output) probeEmulator(ctx context.Context) error {
 	// Disable HTTP keep-alives to ensure no extra goroutines hang around.
 	httpClient := http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
 
@@ -76,46 +160,154 @@ func (o *Output) DialContext(ctx context.Context) error {
 		return fmt.Errorf("unexpected status code: %v", resp.StatusCode)
 	}
 
-	if o.opts.GCPPubsubOptions.Clear {
-		if err := o.clear(ctx); err != nil {
-			return err
-		}
-	}
-
-	if err := o.createTopic(ctx); err != nil {
-		return err
-	}
-
-	if err := o.createSubscription(ctx); err != nil {
-		return err
-	}
-
 	return nil
 }
 
-// Close closes the connection to the configured endpoint.
+// Close closes the connection to the configured endpoint. Any messages that
+// are still batched are published before returning.
 func (o *Output) Close() error {
-	o.client.Topic(o.opts.GCPPubsubOptions.Topic).Stop()
+	if o.topic != nil {
+		o.topic.Stop()
+	}
+	if o.results != nil {
+		close(o.results)
+		<-o.done
+	}
 	o.cancelFunc()
-	return nil
+	return o.err()
 }
 
 // Write writes data to the configured endpoint.
 func (o *Output) Write(b []byte) (int, error) {
+	if o.topic == nil {
+		return 0, errors.New("not connected")
+	}
+
+	if o.opts.GCPPubsubOptions.Async {
+		if err := o.err(); err != nil {
+			return 0, err
+		}
+
+		// The message is published after Write returns, so it needs its own copy.
+		o.results <- o.publish(context.Background(), bytes.Clone(b))
+		return len(b), nil
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	topic := o.client.Topic(o.opts.GCPPubsubOptions.Topic)
-	result := topic.Publish(ctx, &pubsub.Message{Data: b})
-
 	// Wait for message to publish and get assigned ID.
-	if _, err := result.Get(ctx); err != nil {
+	if err := o.get(ctx, o.publish(ctx, b)); err != nil {
 		return 0, err
 	}
 
 	return len(b), nil
 }
 
+// WriteBatch implements output.BatchOutput. All events in batch are published
+// before waiting for the results, so the client can bundle them into a single
+// publish request.
+func (o *Output) WriteBatch(batch [][]byte) error {
+	if o.topic == nil {
+		return errors.New("not connected")
+	}
+
+	if o.opts.GCPPubsubOptions.Async {
+		if err := o.err(); err != nil {
+			return err
+		}
+	}
+
+	results := make([]publishResult, 0, len(batch))
+	for _, b := range batch {
+		results = append(results, o.publish(context.Background(), b))
+	}
+
+	if o.opts.GCPPubsubOptions.Async {
+		for _, result := range results {
+			o.results <- result
+		}
+		return nil
+	}
+
+	var errs error
+	for _, result := range results {
+		errs = errors.Join(errs, o.get(context.Background(), result))
+	}
+	return errs
+}
+
+// publish publishes the message for b.
+func (o *Output) publish(ctx context.Context, b []byte) publishResult {
+	msg := o.newMessage(b)
+	return publishResult{PublishResult: o.topic.Publish(ctx, msg), key: msg.OrderingKey}
+}
+
+// get waits for the result of a published message. A failed publish pauses
+// its ordering key, so the key is resumed to let later messages with it be
+// published.
+func (o *Output) get(ctx context.Context, result publishResult) error {
+	if _, err := result.Get(ctx); err != nil {
+		if result.key == "" {
+			return err
+		}
+		o.topic.ResumePublish(result.key)
+		return fmt.Errorf("ordering key %q: %w", result.key, err)
+	}
+	return nil
+}
+
+// collectResults waits for the asynchronous publish results and records the
+// first error.
+func (o *Output) collectResults() {
+	defer close(o.done)
+	for result := range o.results {
+		if err := o.get(context.Background(), result); err != nil {
+			o.setErr(err)
+		}
+	}
+}
+
+func (o *Output) newMessage(b []byte) *pubsub.Message {
+	msg := &pubsub.Message{
+		Data:        b,
+		OrderingKey: o.opts.GCPPubsubOptions.OrderingKey,
+	}
+
+	if field := o.opts.GCPPubsubOptions.OrderingKeyField; field != "" {
+		msg.OrderingKey, _ = output.JSONField(b, field)
+	}
+
+	if len(o.attributes) > 0 || len(o.fields) > 0 {
+		msg.Attributes = make(map[string]string, len(o.attributes)+len(o.fields))
+		for k, v := range o.attributes {
+			msg.Attributes[k] = v
+		}
+		for k, path := range o.fields {
+			if v, found := output.JSONField(b, path); found {
+				msg.Attributes[k] = v
+			}
+		}
+	}
+
+	return msg
+}
+
+// setErr records the first asynchronous publish error.
+func (o *Output) setErr(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.asyncErr == nil {
+		o.asyncErr = fmt.Errorf("failed to publish message: %w", err)
+	}
+}
+
+func (o *Output) err() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.asyncErr
+}
+
 func (o *Output) clear(ctx context.Context) error {
 	// Clear all topics.
 	topics := o.client.Topics(ctx)
@@ -151,6 +343,11 @@ func (o *Output) clear(ctx context.Context) error {
 	return nil
 }
 
+// ordered reports whether messages are published with an ordering key.
+func (o *Output) ordered() bool {
+	return o.opts.GCPPubsubOptions.OrderingKey != "" || o.opts.GCPPubsubOptions.OrderingKeyField != ""
+}
+
 func (o *Output) createTopic(ctx context.Context) error {
 	topic := o.client.Topic(o.opts.GCPPubsubOptions.Topic)
 	exists, err := topic.Exists(ctx)
@@ -179,7 +376,8 @@ func (o *Output) createSubscription(ctx context.Context) error {
 			ctx,
 			o.opts.GCPPubsubOptions.Subscription,
 			pubsub.SubscriptionConfig{
-				Topic: o.client.Topic(o.opts.GCPPubsubOptions.Topic),
+				Topic:                 o.client.Topic(o.opts.GCPPubsubOptions.Topic),
+				EnableMessageOrdering: o.ordered(),
 			},
 		)
 		if err != nil {
@@ -132,3 +132,54 @@ func TestGCPPubsub(t *testing.T) {
 	}))
 	assert.Equal(t, string(data), string(recvData))
 }
+
+func TestGCPPubsubAttributesAsync(t *testing.T) {
+	const asyncSubscription = "testAsyncSubscription"
+
+	out, err := New(&output.Options{
+		Addr: fmt.Sprintf("%s:%s", emulatorHost, emulatorPort),
+		GCPPubsubOptions: output.GCPPubsubOptions{
+			Project:          project,
+			Topic:            topic,
+			Subscription:     asyncSubscription,
+			Clear:            true,
+			Attributes:       []string{"source=stream"},
+			AttributeFields:  []string{"user=user.id"},
+			OrderingKeyField: "user.id",
+			Async:            true,
+			BatchCount:       10,
+			BatchDelay:       50 * time.Millisecond,
+		},
+	})
+	require.NoError(t, err)
+
+	err = out.DialContext(context.Background())
+	require.NoError(t, err)
+
+	data := []byte(`{"user": {"id": "u1"}}`)
+	n, err := out.Write(data)
+	require.NoError(t, err)
+	assert.Equal(t, len(data), n)
+
+	// Close flushes the pending batch.
+	require.NoError(t, out.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := pubsub.NewClient(ctx, project)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	t.Cleanup(cancel)
+
+	recvCtx, recvCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(recvCancel)
+	var recv *pubsub.Message
+	require.NoError(t, client.Subscription(asyncSubscription).Receive(recvCtx, func(_ context.Context, msg *pubsub.Message) {
+		recv = msg
+		msg.Ack()
+		recvCancel()
+	}))
+	require.NotNil(t, recv)
+	assert.Equal(t, string(data), string(recv.Data))
+	assert.Equal(t, map[string]string{"source": "stream", "user": "u1"}, recv.Attributes)
+	assert.Equal(t, "u1", recv.OrderingKey)
+}
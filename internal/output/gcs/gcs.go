@@ -5,14 +5,22 @@
 // Package gcs provides an output implementation for streaming data to Google
 // Cloud Storage (GCS) buckets. It handles the connection setup, bucket creation
 // (if it does not exist), and writing data as objects within the specified
-// bucket.
+// bucket. Data can be split across several objects, one per input file or by
+// rotating on size or line count, and optionally gzip compressed.
 package gcs
 
 import (
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"google.golang.org/api/option"
@@ -26,14 +34,102 @@ func init() {
 
 // Output is a GCS output.
 type Output struct {
-	opts   *output.Options
-	client *storage.Client
+	opts     *output.Options
+	nameTpl  *template.Template
+	metadata map[string]string
+	client   *storage.Client
+	ctx      context.Context
+
+	source string        // Path of the input file currently being written.
+	seq    *atomic.Int64 // Sequence number of the last object created, shared with clones.
+
+	// State of the object currently being written.
 	writer *storage.Writer
+	gzip   *gzip.Writer
+	w      io.Writer
+	bytes  int64
+	lines  int
+}
+
+// objectName is the data available to the object name template.
+type objectName struct {
+	Seq    int       // Seq is the sequence number of the object, starting at 1.
+	Source string    // Source is the base name of the input file.
+	Time   time.Time // Time is the UTC time the object was created.
+	Date   string    // Date is Time formatted as YYYY-MM-DD.
 }
 
 // New returns a new GCS output.
 func New(opts *output.Options) (output.Output, error) {
-	return &Output{opts: opts}, nil
+	nameTpl, err := template.New("object").Parse(opts.GCSOptions.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse gcs object name template: %w", err)
+	}
+
+	metadata, err := output.SplitKeyValues(opts.GCSOptions.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gcs metadata: %w", err)
+	}
+
+	o := &Output{opts: opts, nameTpl: nameTpl, metadata: metadata, seq: new(atomic.Int64)}
+	if err := o.checkObjectName(); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// Clone returns a new GCS output that shares the object sequence counter of
+// o, so that the outputs of workers and reconnects never reuse an object name.
+func (o *Output) Clone() (output.Output, error) {
+	return &Output{opts: o.opts, nameTpl: o.nameTpl, metadata: o.metadata, seq: o.seq}, nil
+}
+
+// checkObjectName returns an error if more than one object can be written but
+// the object name template gives the same name to different objects.
+func (o *Output) checkObjectName() error {
+	gopts := o.opts.GCSOptions
+
+	// Rotation and workers create several objects for the same source, while
+	// object-per-file alone only creates one object per source.
+	sameSource := gopts.RotateBytes > 0 || gopts.RotateLines > 0 || o.opts.WorkerOptions.Workers > 1
+	if !sameSource && !gopts.ObjectPerFile {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	first, err := o.objectName(1, "a.log", now)
+	if err != nil {
+		return err
+	}
+	source := "b.log"
+	if sameSource {
+		source = "a.log"
+	}
+	second, err := o.objectName(2, source, now)
+	if err != nil {
+		return err
+	}
+	if first == second {
+		return fmt.Errorf("gcs object name template %q gives every object the same name, reference .Seq or .Source", gopts.Object)
+	}
+	return nil
+}
+
+// objectName renders the object name template.
+func (o *Output) objectName(seq int, source string, now time.Time) (string, error) {
+	data := objectName{
+		Seq:    seq,
+		Source: source,
+		Time:   now,
+		Date:   now.Format(time.DateOnly),
+	}
+
+	var name strings.Builder
+	if err := o.nameTpl.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("failed to execute gcs object name template: %w", err)
+	}
+	return name.String(), nil
 }
 
 // DialContext connects to the configured endpoint.
@@ -50,22 +146,27 @@ func (o *Output) DialContext(ctx context.Context) error {
 		return err
 	}
 
-	obj := gcsClient.Bucket(o.opts.GCSOptions.Bucket).Object(o.opts.GCSOptions.Object)
-	writer := obj.NewWriter(context.WithoutCancel(ctx))
-	// System tests are failing because a default content type is not set automatically, so we set it here instead.
-	writer.ObjectAttrs.ContentType = o.opts.GCSOptions.ObjectContentType
-	o.writer = writer
+	o.ctx = context.WithoutCancel(ctx)
 
 	return nil
 }
 
+// StartSource implements output.SourceOutput. When objects are created per
+// input file, the current object is finished so the data read from path is
+// written to a new object.
+func (o *Output) StartSource(path string) error {
+	if o.opts.GCSOptions.ObjectPerFile {
+		if err := o.closeObject(); err != nil {
+			return err
+		}
+	}
+	o.source = path
+	return nil
+}
+
 // Close closes the connection to the configured endpoint.
 func (o *Output) Close() error {
-	var closeErr error
-	if o.writer != nil {
-		closeErr = errors.Join(closeErr, o.writer.Close())
-		o.writer = nil
-	}
+	closeErr := o.closeObject()
 	if o.client != nil {
 		closeErr = errors.Join(closeErr, o.client.Close())
 		o.client = nil
@@ -75,17 +176,99 @@ func (o *Output) Close() error {
 
 // Write writes data to the configured endpoint.
 func (o *Output) Write(b []byte) (int, error) {
-	if o.writer == nil {
+	if o.client == nil {
 		return 0, errors.New("not connected")
 	}
 
-	if _, err := o.writer.Write(b); err != nil {
+	if o.rotate(len(b)) {
+		if err := o.closeObject(); err != nil {
+			return 0, err
+		}
+	}
+
+	if o.writer == nil {
+		if err := o.openObject(); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := o.w.Write(b); err != nil {
 		return 0, fmt.Errorf("failed to copy data: %w", err)
 	}
+	o.bytes += int64(len(b))
+	o.lines++
 
 	return len(b), nil
 }
 
+// rotate returns true if writing n more bytes requires starting a new object.
+func (o *Output) rotate(n int) bool {
+	if o.writer == nil || o.lines == 0 {
+		return false
+	}
+
+	if limit := o.opts.GCSOptions.RotateBytes; limit > 0 && o.bytes+int64(n) > limit {
+		return true
+	}
+	if limit := o.opts.GCSOptions.RotateLines; limit > 0 && o.lines >= limit {
+		return true
+	}
+	return false
+}
+
+func (o *Output) openObject() error {
+	var source string
+	if o.source != "" {
+		source = filepath.Base(o.source)
+	}
+
+	name, err := o.objectName(int(o.seq.Add(1)), source, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	obj := o.client.Bucket(o.opts.GCSOptions.Bucket).Object(name)
+	if o.opts.GCSOptions.FailIfExists {
+		obj = obj.If(storage.Conditions{DoesNotExist: true})
+	}
+
+	writer := obj.NewWriter(o.ctx)
+	// System tests are failing because a default content type is not set automatically, so we set it here instead.
+	writer.ObjectAttrs.ContentType = o.opts.GCSOptions.ObjectContentType
+	writer.ObjectAttrs.Metadata = o.metadata
+	o.writer = writer
+	o.w = writer
+
+	if o.opts.GCSOptions.Gzip {
+		writer.ObjectAttrs.ContentEncoding = "gzip"
+		o.gzip = gzip.NewWriter(writer)
+		o.w = o.gzip
+	}
+
+	return nil
+}
+
+// closeObject finishes the object currently being written. The object is only
+// created in the bucket once it is closed.
+func (o *Output) closeObject() error {
+	if o.writer == nil {
+		return nil
+	}
+
+	var err error
+	if o.gzip != nil {
+		err = o.gzip.Close()
+	}
+	err = errors.Join(err, o.writer.Close())
+	if err != nil {
+		err = fmt.Errorf("failed to write object %q: %w", o.writer.ObjectAttrs.Name, err)
+	}
+
+	o.writer, o.gzip, o.w = nil, nil, nil
+	o.bytes, o.lines = 0, 0
+	return err
+}
+
 func (o *Output) createBucket(ctx context.Context) error {
 	bkt := o.client.Bucket(o.opts.GCSOptions.Bucket)
 	_, err := bkt.Attrs(ctx)
@@ -5,6 +5,7 @@
 package gcs
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -94,6 +95,44 @@ func TestCloseWithoutDial(t *testing.T) {
 	require.NoError(t, out.Close())
 }
 
+func TestObjectNameCheck(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts output.Options
+		fail bool
+	}{
+		{name: "single object", opts: output.Options{GCSOptions: output.GCSOptions{Object: "testobject"}}},
+		{name: "rotation without seq", opts: output.Options{GCSOptions: output.GCSOptions{Object: "{{ .Source }}", RotateLines: 10}}, fail: true},
+		{name: "rotation with seq", opts: output.Options{GCSOptions: output.GCSOptions{Object: "{{ .Seq }}", RotateBytes: 10}}},
+		{name: "object per file without source", opts: output.Options{GCSOptions: output.GCSOptions{Object: "{{ .Date }}", ObjectPerFile: true}}, fail: true},
+		{name: "object per file with source", opts: output.Options{GCSOptions: output.GCSOptions{Object: "{{ .Source }}", ObjectPerFile: true}}},
+		{name: "workers without seq", opts: output.Options{GCSOptions: output.GCSOptions{Object: "testobject"}, WorkerOptions: output.WorkerOptions{Workers: 2}}, fail: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(&tc.opts)
+			if tc.fail {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSharedSequence(t *testing.T) {
+	opts := &output.Options{GCSOptions: output.GCSOptions{Object: "{{ .Seq }}", RotateLines: 1}}
+
+	a, err := New(opts)
+	require.NoError(t, err)
+	b, err := a.(output.Cloner).Clone()
+	require.NoError(t, err)
+
+	a.(*Output).seq.Add(1)
+	assert.Equal(t, int64(2), b.(*Output).seq.Add(1))
+}
+
 func TestGcs(t *testing.T) {
 	out, err := New(&output.Options{
 		Addr: emulatorHostAndPort,
@@ -137,3 +176,64 @@ func TestGcs(t *testing.T) {
 
 	assert.Equal(t, string(data), string(body))
 }
+
+func TestGcsRotation(t *testing.T) {
+	const rotationBucket = "rotationbucket"
+
+	out, err := New(&output.Options{
+		Addr: emulatorHostAndPort,
+		GCSOptions: output.GCSOptions{
+			Bucket:        rotationBucket,
+			Object:        "logs/{{ .Source }}-{{ .Seq }}.json.gz",
+			ObjectPerFile: true,
+			RotateLines:   2,
+			Gzip:          true,
+			Metadata:      []string{"origin=stream"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.DialContext(context.Background()))
+
+	so, ok := out.(output.SourceOutput)
+	require.True(t, ok)
+
+	// Three lines from a.log rotate after two lines, then b.log starts a new object.
+	require.NoError(t, so.StartSource("/data/a.log"))
+	for _, line := range []string{"a1", "a2", "a3"} {
+		_, err = out.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, so.StartSource("/data/b.log"))
+	_, err = out.Write([]byte("b1"))
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	readCtx := context.Background()
+	gcsClient, err := NewClient(readCtx, emulatorHostAndPort)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = gcsClient.Close() })
+
+	want := map[string]string{
+		"logs/a.log-1.json.gz": "a1a2",
+		"logs/a.log-2.json.gz": "a3",
+		"logs/b.log-3.json.gz": "b1",
+	}
+	for name, content := range want {
+		obj := gcsClient.Bucket(rotationBucket).Object(name)
+
+		attrs, err := obj.Attrs(readCtx)
+		require.NoError(t, err, name)
+		assert.Equal(t, "gzip", attrs.ContentEncoding)
+		assert.Equal(t, "stream", attrs.Metadata["origin"])
+
+		r, err := obj.ReadCompressed(true).NewReader(readCtx)
+		require.NoError(t, err, name)
+		gz, err := gzip.NewReader(r)
+		require.NoError(t, err, name)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err, name)
+		r.Close()
+
+		assert.Equal(t, content, string(body), name)
+	}
+}
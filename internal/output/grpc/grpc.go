@@ -0,0 +1,311 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package grpcout provides an output that sends events to any gRPC service.
+// The service is described by a protobuf FileDescriptorSet, and each JSON event
+// is decoded into a dynamic request message, so no generated client code is
+// needed. Unary and client-streaming methods are supported.
+package grpcout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+// defaultDialTimeout is the time to wait for the connection to be ready when
+// no dial timeout is set.
+const defaultDialTimeout = 10 * time.Second
+
+func init() {
+	output.Register("grpc", New)
+}
+
+// Output is a gRPC output.
+type Output struct {
+	opts      *output.Options
+	method    protoreflect.MethodDescriptor
+	fullName  string                       // Method name in the /package.Service/Method form.
+	field     protoreflect.FieldDescriptor // Nil if events are decoded into the request.
+	unmarshal protojson.UnmarshalOptions
+	md        metadata.MD
+
+	conn   *grpc.ClientConn
+	stream grpc.ClientStream // Open stream of a client-streaming method.
+	cancel context.CancelFunc
+}
+
+// New returns a new gRPC output.
+func New(opts *output.Options) (output.Output, error) {
+	gopts := opts.GRPCOptions
+	if opts.Addr == "" {
+		return nil, errors.New("grpc address is required")
+	}
+
+	method, err := findMethod(gopts.DescriptorSet, gopts.Method)
+	if err != nil {
+		return nil, err
+	}
+	if method.IsStreamingServer() {
+		return nil, fmt.Errorf("grpc method %s is server-streaming, only unary and client-streaming methods are supported", method.FullName())
+	}
+
+	o := &Output{
+		opts:      opts,
+		method:    method,
+		fullName:  fmt.Sprintf("/%s/%s", method.Parent().FullName(), method.Name()),
+		unmarshal: protojson.UnmarshalOptions{DiscardUnknown: gopts.DiscardUnknown},
+	}
+
+	if gopts.RequestField != "" {
+		o.field = method.Input().Fields().ByName(protoreflect.Name(gopts.RequestField))
+		if o.field == nil {
+			return nil, fmt.Errorf("grpc request %s has no field %q", method.Input().FullName(), gopts.RequestField)
+		}
+		if o.field.Kind() != protoreflect.MessageKind || o.field.IsMap() {
+			return nil, fmt.Errorf("grpc request field %q must be a message or a repeated message", gopts.RequestField)
+		}
+	}
+
+	md, err := output.SplitKeyValues(gopts.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	o.md = metadata.New(md)
+
+	return o, nil
+}
+
+// findMethod returns the method named name (package.Service/Method) from the
+// FileDescriptorSet in path.
+func findMethod(path, name string) (protoreflect.MethodDescriptor, error) {
+	if path == "" {
+		return nil, errors.New("grpc descriptor set is required")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err = proto.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set (it must include imports): %w", err)
+	}
+
+	service, methodName, ok := strings.Cut(strings.TrimPrefix(name, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid grpc method %q (use package.Service/Method)", name)
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("grpc service %s not found: %w", service, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a grpc service", service)
+	}
+	method := sd.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, fmt.Errorf("grpc service %s has no method %s", service, methodName)
+	}
+	return method, nil
+}
+
+// DialContext connects to the configured endpoint. For client-streaming
+// methods the stream is opened.
+func (o *Output) DialContext(ctx context.Context) error {
+	creds := insecure.NewCredentials()
+	if o.opts.GRPCOptions.TLS {
+		tlsConfig, err := o.opts.TLSConfig()
+		if err != nil {
+			return err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if o.opts.Proxy != "" {
+		dial, err := o.opts.Dialer(&net.Dialer{Timeout: time.Second})
+		if err != nil {
+			return err
+		}
+		dialOpts = append(dialOpts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dial(ctx, "tcp", addr)
+		}))
+	}
+
+	conn, err := grpc.NewClient(o.opts.Addr, dialOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to create grpc client: %w", err)
+	}
+
+	// Unlike dialing, creating a client does not connect, so wait for the
+	// connection to be ready.
+	conn.Connect()
+	dialTimeout := o.opts.GRPCOptions.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultDialTimeout
+	}
+	readyCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	err = waitForReady(readyCtx, conn)
+	cancel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	o.conn = conn
+
+	if o.method.IsStreamingClient() {
+		streamCtx, cancel := context.WithCancel(metadata.NewOutgoingContext(context.Background(), o.md))
+		desc := &grpc.StreamDesc{StreamName: string(o.method.Name()), ClientStreams: true}
+		stream, err := conn.NewStream(streamCtx, desc, o.fullName)
+		if err != nil {
+			cancel()
+			conn.Close()
+			o.conn = nil
+			return fmt.Errorf("failed to open grpc stream: %w", err)
+		}
+		o.stream, o.cancel = stream, cancel
+	}
+	return nil
+}
+
+func waitForReady(ctx context.Context, conn *grpc.ClientConn) error {
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			// The client would keep retrying in the background, but the
+			// dial is failed so that the retries and backoff of the
+			// caller apply.
+			return fmt.Errorf("failed to connect to grpc server (state %s)", state)
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("failed to connect to grpc server (state %s): %w", state, ctx.Err())
+		}
+	}
+}
+
+// Close closes the connection. For client-streaming methods the stream is
+// closed and the response is received first.
+func (o *Output) Close() error {
+	var err error
+	if o.stream != nil {
+		if err = o.stream.CloseSend(); err == nil {
+			err = o.stream.RecvMsg(dynamicpb.NewMessage(o.method.Output()))
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+		}
+		o.cancel()
+		o.stream = nil
+	}
+	if o.conn != nil {
+		err = errors.Join(err, o.conn.Close())
+		o.conn = nil
+	}
+	return err
+}
+
+// Write decodes the JSON event in b into a request message and sends it.
+func (o *Output) Write(b []byte) (int, error) {
+	if err := o.WriteBatch([][]byte{b}); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// WriteBatch implements output.BatchOutput. If the request field is repeated,
+// all events in batch are sent in one request, otherwise each event is sent
+// in its own request.
+func (o *Output) WriteBatch(batch [][]byte) error {
+	if o.conn == nil {
+		return errors.New("not connected")
+	}
+
+	if o.field != nil && o.field.IsList() {
+		req := dynamicpb.NewMessage(o.method.Input())
+		list := req.Mutable(o.field).List()
+		for _, b := range batch {
+			event := list.NewElement()
+			if err := o.unmarshal.Unmarshal(b, event.Message().Interface()); err != nil {
+				return fmt.Errorf("failed to decode event into %s: %w", o.field.Message().FullName(), err)
+			}
+			list.Append(event)
+		}
+		return o.send(req)
+	}
+
+	for _, b := range batch {
+		req, err := o.newRequest(b)
+		if err != nil {
+			return err
+		}
+		if err = o.send(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newRequest returns a request message with the event in b.
+func (o *Output) newRequest(b []byte) (*dynamicpb.Message, error) {
+	req := dynamicpb.NewMessage(o.method.Input())
+	target := proto.Message(req)
+	if o.field != nil {
+		target = req.Mutable(o.field).Message().Interface()
+	}
+	if err := o.unmarshal.Unmarshal(b, target); err != nil {
+		return nil, fmt.Errorf("failed to decode event into %s: %w", target.ProtoReflect().Descriptor().FullName(), err)
+	}
+	return req, nil
+}
+
+func (o *Output) send(req *dynamicpb.Message) error {
+	if o.stream != nil {
+		if err := o.stream.SendMsg(req); err != nil {
+			if errors.Is(err, io.EOF) {
+				// The stream was aborted, the status is returned by RecvMsg.
+				err = o.stream.RecvMsg(dynamicpb.NewMessage(o.method.Output()))
+			}
+			return fmt.Errorf("failed to send grpc stream message: %w", err)
+		}
+		return nil
+	}
+
+	ctx := metadata.NewOutgoingContext(context.Background(), o.md)
+	if timeout := o.opts.GRPCOptions.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	resp := dynamicpb.NewMessage(o.method.Output())
+	if err := o.conn.Invoke(ctx, o.fullName, req, resp); err != nil {
+		return fmt.Errorf("grpc call %s failed: %w", o.fullName, err)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package grpcout
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+// testFile describes:
+//
+//	package test;
+//	message Event { string message = 1; int64 count = 2; }
+//	message Request { repeated Event events = 1; Event event = 2; }
+//	message Response { int32 accepted = 1; }
+//	service Intake {
+//	  rpc Send(Request) returns (Response);
+//	  rpc Stream(stream Event) returns (Response);
+//	  rpc Watch(Request) returns (stream Response);
+//	}
+var testFile = &descriptorpb.FileDescriptorProto{
+	Name:    proto.String("test.proto"),
+	Package: proto.String("test"),
+	Syntax:  proto.String("proto3"),
+	MessageType: []*descriptorpb.DescriptorProto{
+		{
+			Name: proto.String("Event"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("message", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+				field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, "", false),
+			},
+		},
+		{
+			Name: proto.String("Request"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("events", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Event", true),
+				field("event", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Event", false),
+			},
+		},
+		{
+			Name: proto.String("Response"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("accepted", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, "", false),
+			},
+		},
+	},
+	Service: []*descriptorpb.ServiceDescriptorProto{
+		{
+			Name: proto.String("Intake"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("Send"), InputType: proto.String(".test.Request"), OutputType: proto.String(".test.Response")},
+				{Name: proto.String("Stream"), InputType: proto.String(".test.Event"), OutputType: proto.String(".test.Response"), ClientStreaming: proto.Bool(true)},
+				{Name: proto.String("Watch"), InputType: proto.String(".test.Request"), OutputType: proto.String(".test.Response"), ServerStreaming: proto.Bool(true)},
+			},
+		},
+	},
+}
+
+func field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Type:     typ.Enum(),
+		Label:    label.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+// writeDescriptorSet writes the test descriptor set to a file and returns its
+// path.
+func writeDescriptorSet(t *testing.T) string {
+	t.Helper()
+
+	b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{testFile}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "test.protoset")
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+	return path
+}
+
+type call struct {
+	method   string
+	metadata metadata.MD
+	messages []string // Received messages in protojson.
+}
+
+// startServer starts a gRPC server that handles every method of the test
+// service and records the calls.
+func startServer(t *testing.T) (string, func() []call) {
+	t.Helper()
+
+	fd, err := protodesc.NewFile(testFile, nil)
+	require.NoError(t, err)
+	methods := fd.Services().Get(0).Methods()
+
+	var mu sync.Mutex
+	var calls []call
+	handler := func(_ any, stream grpc.ServerStream) error {
+		name, _ := grpc.MethodFromServerStream(stream)
+		method := methods.ByName(protoreflect.Name(name[len("/test.Intake/"):]))
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		c := call{method: name, metadata: md}
+
+		for {
+			msg := dynamicpb.NewMessage(method.Input())
+			err := stream.RecvMsg(msg)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			b, err := protojson.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			c.messages = append(c.messages, string(b))
+		}
+
+		mu.Lock()
+		calls = append(calls, c)
+		mu.Unlock()
+
+		resp := dynamicpb.NewMessage(method.Output())
+		resp.Set(method.Output().Fields().ByName("accepted"), protoreflect.ValueOfInt32(int32(len(c.messages))))
+		return stream.SendMsg(resp)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(handler))
+	go srv.Serve(l) //nolint:errcheck // Test server.
+	t.Cleanup(srv.Stop)
+
+	return l.Addr().String(), func() []call {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]call(nil), calls...)
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	descriptorSet := writeDescriptorSet(t)
+	events := []string{`{"message":"a","count":1}`, `{"message":"b"}`}
+
+	testCases := []struct {
+		name  string
+		opts  output.GRPCOptions
+		batch bool
+		want  []call
+	}{
+		{
+			name: "unary request field",
+			opts: output.GRPCOptions{Method: "test.Intake/Send", RequestField: "event"},
+			want: []call{
+				{method: "/test.Intake/Send", messages: []string{`{"event":{"message":"a","count":"1"}}`}},
+				{method: "/test.Intake/Send", messages: []string{`{"event":{"message":"b"}}`}},
+			},
+		},
+		{
+			name: "unary whole request",
+			opts: output.GRPCOptions{Method: "/test.Intake/Send", DiscardUnknown: true},
+			want: []call{
+				{method: "/test.Intake/Send", messages: []string{`{}`}},
+				{method: "/test.Intake/Send", messages: []string{`{}`}},
+			},
+		},
+		{
+			name:  "unary repeated field batch",
+			opts:  output.GRPCOptions{Method: "test.Intake/Send", RequestField: "events"},
+			batch: true,
+			want: []call{
+				{method: "/test.Intake/Send", messages: []string{`{"events":[{"message":"a","count":"1"},{"message":"b"}]}`}},
+			},
+		},
+		{
+			name: "client streaming",
+			opts: output.GRPCOptions{Method: "test.Intake/Stream"},
+			want: []call{
+				{method: "/test.Intake/Stream", messages: []string{`{"message":"a","count":"1"}`, `{"message":"b"}`}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr, calls := startServer(t)
+
+			tc.opts.DescriptorSet = descriptorSet
+			tc.opts.Metadata = []string{"x-api-key=secret"}
+			out, err := New(&output.Options{Addr: addr, GRPCOptions: tc.opts})
+			require.NoError(t, err)
+			require.NoError(t, out.DialContext(context.Background()))
+
+			if tc.batch {
+				require.NoError(t, out.(output.BatchOutput).WriteBatch([][]byte{[]byte(events[0]), []byte(events[1])}))
+			} else {
+				for _, e := range events {
+					_, err = out.Write([]byte(e))
+					require.NoError(t, err)
+				}
+			}
+			require.NoError(t, out.Close())
+
+			got := calls()
+			require.Len(t, got, len(tc.want))
+			for i, c := range got {
+				assert.Equal(t, tc.want[i].method, c.method)
+				assert.Equal(t, []string{"secret"}, c.metadata.Get("x-api-key"))
+				require.Len(t, c.messages, len(tc.want[i].messages))
+				for j, m := range c.messages {
+					assert.JSONEq(t, tc.want[i].messages[j], m)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCInvalidEvent(t *testing.T) {
+	addr, _ := startServer(t)
+
+	out, err := New(&output.Options{
+		Addr:        addr,
+		GRPCOptions: output.GRPCOptions{DescriptorSet: writeDescriptorSet(t), Method: "test.Intake/Send", RequestField: "event"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.DialContext(context.Background()))
+	defer out.Close()
+
+	_, err = out.Write([]byte(`{"unknown":true}`))
+	assert.ErrorContains(t, err, "failed to decode event into test.Event")
+}
+
+func TestNew(t *testing.T) {
+	descriptorSet := writeDescriptorSet(t)
+
+	for _, opts := range []output.GRPCOptions{
+		{Method: "test.Intake/Send"},
+		{DescriptorSet: descriptorSet, Method: "test.Intake"},
+		{DescriptorSet: descriptorSet, Method: "test.Missing/Send"},
+		{DescriptorSet: descriptorSet, Method: "test.Intake/Missing"},
+		{DescriptorSet: descriptorSet, Method: "test.Event/Send"},
+		{DescriptorSet: descriptorSet, Method: "test.Intake/Watch"},
+		{DescriptorSet: descriptorSet, Method: "test.Intake/Send", RequestField: "missing"},
+		{DescriptorSet: descriptorSet, Method: "test.Intake/Stream", RequestField: "message"},
+	} {
+		_, err := New(&output.Options{Addr: "localhost:1234", GRPCOptions: opts})
+		assert.Error(t, err, "%+v", opts)
+	}
+}
+
+func TestGRPCUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	out, err := New(&output.Options{Addr: addr, GRPCOptions: output.GRPCOptions{
+		DescriptorSet: writeDescriptorSet(t),
+		Method:        "test.Intake/Send",
+		DialTimeout:   time.Minute,
+	}})
+	require.NoError(t, err)
+
+	// The dial fails once the connection fails instead of blocking until the
+	// dial timeout.
+	start := time.Now()
+	err = out.DialContext(context.Background())
+	assert.ErrorContains(t, err, "TRANSIENT_FAILURE")
+	assert.Less(t, time.Since(start), 30*time.Second)
+}
@@ -10,24 +10,43 @@
 package kafka
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/IBM/sarama"
 
 	"github.com/elastic/stream/internal/output"
 )
 
+// noValue is what a template renders for a missing map key, including a key
+// of a missing map such as .user.id when the event has no user.
+const noValue = "<no value>"
+
 func init() {
 	output.Register("kafka", New)
 }
 
 // Output is a kafka output.
 type Output struct {
-	opts   *output.Options
-	client sarama.SyncProducer
-	config *sarama.Config
+	opts    *output.Options
+	brokers []string
+	conn    sarama.Client // Shared by the producers, which don't close it.
+	client  sarama.SyncProducer
+	async   sarama.AsyncProducer
+	config  *sarama.Config
+	keyTpl  *template.Template
+	headers []sarama.RecordHeader
+
+	mu       sync.Mutex
+	asyncErr error // First error reported by the async producer.
+	done     chan struct{}
 }
 
 // New returns a new kafka output.
@@ -36,21 +55,141 @@ func New(opts *output.Options) (output.Output, error) {
 		return nil, errors.New("kafka address is required")
 	}
 
-	config := sarama.NewConfig()
-	config.Producer.Partitioner = sarama.NewRandomPartitioner
-	config.Producer.RequiredAcks = sarama.WaitForAll
-	config.Producer.Return.Successes = true
-	saramaClient, err := sarama.NewClient([]string{opts.Addr}, config)
+	config, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Output{opts: opts, brokers: splitBrokers(opts.Addr), config: config}
+
+	if opts.KafkaOptions.KeyTemplate != "" {
+		o.keyTpl, err = template.New("key").Parse(opts.KafkaOptions.KeyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse kafka key template: %w", err)
+		}
+	}
+
+	if _, err := output.SplitKeyValues(opts.KafkaOptions.Headers); err != nil {
+		return nil, fmt.Errorf("invalid kafka header: %w", err)
+	}
+	// Headers are added in the order of the flags.
+	for _, kv := range opts.KafkaOptions.Headers {
+		k, v, _ := strings.Cut(kv, "=")
+		o.headers = append(o.headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+	}
+
+	o.conn, err = sarama.NewClient(o.brokers, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create sarama client: %w", err)
 	}
 
-	producer, err := sarama.NewSyncProducerFromClient(saramaClient)
+	if opts.KafkaOptions.Async {
+		producer, err := sarama.NewAsyncProducerFromClient(o.conn)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to create async producer client: %w", err), o.conn.Close())
+		}
+		o.async = producer
+		o.done = make(chan struct{})
+		go o.collectErrors()
+		return o, nil
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(o.conn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create producer client: %w", err)
+		return nil, errors.Join(fmt.Errorf("failed to create producer client: %w", err), o.conn.Close())
 	}
+	o.client = producer
 
-	return &Output{opts: opts, client: producer, config: config}, nil
+	return o, nil
+}
+
+// newConfig builds the sarama configuration from the output options.
+func newConfig(opts *output.Options) (*sarama.Config, error) {
+	kopts := opts.KafkaOptions
+
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = !kopts.Async
+	config.Producer.Return.Errors = true
+
+	switch kopts.Partitioner {
+	case "", "hash":
+		// Events without a key are randomly distributed.
+		config.Producer.Partitioner = sarama.NewHashPartitioner
+	case "random":
+		config.Producer.Partitioner = sarama.NewRandomPartitioner
+	case "roundrobin":
+		config.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	default:
+		return nil, fmt.Errorf("unknown kafka partitioner %q (use hash, random or roundrobin)", kopts.Partitioner)
+	}
+
+	if kopts.Compression != "" {
+		if err := config.Producer.Compression.UnmarshalText([]byte(kopts.Compression)); err != nil {
+			return nil, err
+		}
+	}
+
+	if kopts.TLS {
+		config.Net.TLS.Enable = true
+		tlsConfig, err := opts.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		config.Net.TLS.Config = tlsConfig
+	}
+
+	proxyURL, err := opts.ProxyURL()
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL != nil {
+		dial, err := opts.Dialer(&net.Dialer{Timeout: config.Net.DialTimeout})
+		if err != nil {
+			return nil, err
+		}
+		config.Net.Proxy.Enable = true
+		config.Net.Proxy.Dialer = dial
+	}
+
+	if kopts.SASLMechanism != "" {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.User = kopts.Username
+		config.Net.SASL.Password = kopts.Password
+
+		switch strings.ToUpper(kopts.SASLMechanism) {
+		case sarama.SASLTypePlaintext:
+			config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		case sarama.SASLTypeSCRAMSHA256:
+			config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+			config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{HashGeneratorFcn: sha256Hash} }
+		case sarama.SASLTypeSCRAMSHA512:
+			config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+			config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{HashGeneratorFcn: sha512Hash} }
+		case sarama.SASLTypeOAuth:
+			config.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+			config.Net.SASL.TokenProvider = staticTokenProvider(kopts.OAuthToken)
+		default:
+			return nil, fmt.Errorf("unknown kafka SASL mechanism %q (use PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER)", kopts.SASLMechanism)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kafka configuration: %w", err)
+	}
+
+	return config, nil
+}
+
+// splitBrokers splits a comma separated list of bootstrap brokers.
+func splitBrokers(addr string) []string {
+	var brokers []string
+	for _, b := range strings.Split(addr, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	return brokers
 }
 
 // DialContext connects to the configured endpoint.
@@ -64,17 +203,34 @@ func (o *Output) DialContext(_ context.Context) error {
 
 // Close closes the connection to the configured endpoint.
 func (o *Output) Close() error {
-	o.client.Close()
-	return nil
+	if o.async != nil {
+		// Close flushes buffered messages. Errors are collected by collectErrors.
+		o.async.AsyncClose()
+		<-o.done
+		return errors.Join(o.err(), o.conn.Close())
+	}
+
+	return errors.Join(o.client.Close(), o.conn.Close())
 }
 
 // Write writes data to the kafka topic.
 func (o *Output) Write(b []byte) (int, error) {
-	msg := &sarama.ProducerMessage{
-		Topic: o.opts.KafkaOptions.Topic,
-		Value: sarama.ByteEncoder(b),
+	msg, err := o.newMessage(b)
+	if err != nil {
+		return 0, err
 	}
-	_, _, err := o.client.SendMessage(msg)
+
+	if o.async != nil {
+		if err := o.err(); err != nil {
+			return 0, err
+		}
+		// The producer holds on to the message, so it needs its own copy.
+		msg.Value = sarama.ByteEncoder(bytes.Clone(b))
+		o.async.Input() <- msg
+		return len(b), nil
+	}
+
+	_, _, err = o.client.SendMessage(msg)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create data in kafka topic: %w", err)
 	}
@@ -82,18 +238,154 @@ func (o *Output) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
+// WriteBatch implements output.BatchOutput. It sends all events in batch with a
+// single produce request per broker.
+func (o *Output) WriteBatch(batch [][]byte) error {
+	msgs := make([]*sarama.ProducerMessage, 0, len(batch))
+	for _, b := range batch {
+		msg, err := o.newMessage(b)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	if o.async != nil {
+		if err := o.err(); err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			o.async.Input() <- msg
+		}
+		return nil
+	}
+
+	if err := o.client.SendMessages(msgs); err != nil {
+		return fmt.Errorf("failed to create data in kafka topic: %w", err)
+	}
+	return nil
+}
+
+func (o *Output) newMessage(b []byte) (*sarama.ProducerMessage, error) {
+	msg := &sarama.ProducerMessage{
+		Topic:   o.opts.KafkaOptions.Topic,
+		Value:   sarama.ByteEncoder(b),
+		Headers: o.headers,
+	}
+
+	key, err := o.key(b)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+
+	return msg, nil
+}
+
+// key returns the message key for b. An empty key means no key is set.
+func (o *Output) key(b []byte) (string, error) {
+	if field := o.opts.KafkaOptions.KeyField; field != "" {
+		key, _ := output.JSONField(b, field)
+		return key, nil
+	}
+
+	if o.keyTpl == nil {
+		return "", nil
+	}
+
+	// Templates are evaluated against the decoded JSON event. Lines that are
+	// not valid JSON are passed to the template as a string.
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		data = string(b)
+	}
+	// Null fields are removed so that, like missing fields, they render as
+	// "<no value>" instead of failing to evaluate their keys.
+	dropNulls(data)
+
+	var buf strings.Builder
+	if err := o.keyTpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute kafka key template: %w", err)
+	}
+	// A missing field renders as "<no value>", which like a missing
+	// --kafka-key-field means the message has no key.
+	if buf.String() == noValue {
+		return "", nil
+	}
+	return buf.String(), nil
+}
+
+// dropNulls removes the null fields from the objects in v.
+func dropNulls(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, f := range v {
+			if f == nil {
+				delete(v, k)
+				continue
+			}
+			dropNulls(f)
+		}
+	case []interface{}:
+		for _, e := range v {
+			dropNulls(e)
+		}
+	}
+}
+
+// collectErrors records the first error reported by the async producer.
+func (o *Output) collectErrors() {
+	defer close(o.done)
+	for err := range o.async.Errors() {
+		o.mu.Lock()
+		if o.asyncErr == nil {
+			o.asyncErr = fmt.Errorf("failed to create data in kafka topic: %w", err)
+		}
+		o.mu.Unlock()
+	}
+}
+
+func (o *Output) err() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.asyncErr
+}
+
 func (o *Output) createTopic() error {
-	admin, err := sarama.NewClusterAdmin([]string{o.opts.Addr}, o.config)
+	admin, err := sarama.NewClusterAdmin(o.brokers, o.config)
 	if err != nil {
 		return fmt.Errorf("failed to create cluster admin client: %w", err)
 	}
+	defer admin.Close()
+
+	partitions := o.opts.KafkaOptions.Partitions
+	if partitions <= 0 {
+		partitions = 1
+	}
+	replicationFactor := o.opts.KafkaOptions.ReplicationFactor
+	if replicationFactor <= 0 {
+		replicationFactor = 1
+	}
 
 	err = admin.CreateTopic(o.opts.KafkaOptions.Topic, &sarama.TopicDetail{
-		NumPartitions:     1,
-		ReplicationFactor: 1,
+		NumPartitions:     partitions,
+		ReplicationFactor: replicationFactor,
 	}, false)
 	if err != nil {
 		return fmt.Errorf("failed to create topic: %w", err)
 	}
 	return nil
 }
+
+// staticTokenProvider is a sarama.AccessTokenProvider that always returns the
+// same OAUTHBEARER token.
+type staticTokenProvider string
+
+func (p staticTokenProvider) Token() (*sarama.AccessToken, error) {
+	if p == "" {
+		return nil, errors.New("kafka OAUTHBEARER token is empty")
+	}
+	return &sarama.AccessToken{Token: string(p)}, nil
+}
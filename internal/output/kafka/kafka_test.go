@@ -5,11 +5,13 @@
 package kafka
 
 import (
+	"context"
 	"log"
 	"net"
 	"os"
 	"strings"
 	"testing"
+	"text/template"
 
 	"github.com/IBM/sarama"
 	"github.com/ory/dockertest/v3"
@@ -113,3 +115,99 @@ func TestKafka(t *testing.T) {
 		}
 	}
 }
+
+func TestKafkaConfig(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts output.KafkaOptions
+		fail bool
+	}{
+		{name: "defaults"},
+		{name: "gzip", opts: output.KafkaOptions{Compression: "gzip"}},
+		{name: "bad compression", opts: output.KafkaOptions{Compression: "brotli"}, fail: true},
+		{name: "roundrobin", opts: output.KafkaOptions{Partitioner: "roundrobin"}},
+		{name: "bad partitioner", opts: output.KafkaOptions{Partitioner: "sticky"}, fail: true},
+		{name: "plain", opts: output.KafkaOptions{SASLMechanism: "plain", Username: "u", Password: "p"}},
+		{name: "plain without password", opts: output.KafkaOptions{SASLMechanism: "PLAIN", Username: "u"}, fail: true},
+		{name: "scram", opts: output.KafkaOptions{SASLMechanism: "SCRAM-SHA-512", Username: "u", Password: "p"}},
+		{name: "oauth", opts: output.KafkaOptions{SASLMechanism: "OAUTHBEARER", OAuthToken: "token"}},
+		{name: "bad mechanism", opts: output.KafkaOptions{SASLMechanism: "GSSAPI"}, fail: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newConfig(&output.Options{KafkaOptions: tc.opts})
+			if tc.fail {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestKafkaKey(t *testing.T) {
+	o := &Output{opts: &output.Options{}}
+	o.keyTpl = template.Must(template.New("key").Parse("{{ .id }}"))
+
+	key, err := o.key([]byte(`{"id": "u1"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "u1", key)
+
+	key, err = o.key([]byte(`{"name": "u1"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "", key, "a missing field means no key")
+}
+
+func TestKafkaKeyNested(t *testing.T) {
+	o := &Output{opts: &output.Options{}}
+	o.keyTpl = template.Must(template.New("key").Parse("{{ .user.id }}"))
+
+	key, err := o.key([]byte(`{"user": {"id": "u1"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "u1", key)
+
+	for _, event := range []string{`{"name": "u1"}`, `{"user": {}}`, `{"user": null}`} {
+		key, err = o.key([]byte(event))
+		require.NoError(t, err, event)
+		assert.Equal(t, "", key, "a missing field means no key: %s", event)
+	}
+}
+
+func TestKafkaKeyAndHeaders(t *testing.T) {
+	const keyedTopic = "testKeyedTopic"
+
+	out, err := New(&output.Options{
+		Addr: emulatorHostAndPort,
+		KafkaOptions: output.KafkaOptions{
+			Topic:       keyedTopic,
+			KeyTemplate: "{{ .user.id }}",
+			Headers:     []string{"source=stream", "a=1", "b=2"},
+			Compression: "gzip",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.DialContext(context.Background()))
+	defer out.Close()
+
+	event := `{"user": {"id": "u1"}}`
+	_, err = out.Write([]byte(event))
+	require.NoError(t, err)
+
+	consumer, err := sarama.NewConsumer([]string{emulatorHostAndPort}, sarama.NewConfig())
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	pc, err := consumer.ConsumePartition(keyedTopic, 0, sarama.OffsetOldest)
+	require.NoError(t, err)
+	defer pc.Close()
+
+	msg := <-pc.Messages()
+	assert.Equal(t, "u1", string(msg.Key))
+	assert.Equal(t, event, string(msg.Value))
+	require.Len(t, msg.Headers, 3)
+	for i, want := range []string{"source", "a", "b"} {
+		assert.Equal(t, want, string(msg.Headers[i].Key))
+	}
+	assert.Equal(t, "stream", string(msg.Headers[0].Value))
+}
@@ -12,13 +12,13 @@ package lumberjack
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	v2 "github.com/elastic/go-lumber/client/v2"
@@ -30,12 +30,32 @@ func init() {
 	output.Register("lumberjack", New)
 }
 
+// syncClient sends a batch of events and blocks until it is ACKed.
+type syncClient interface {
+	Send(data []interface{}) (int, error)
+	Close() error
+}
+
 // Output is a lumberjack output.
 type Output struct {
 	opts    *output.Options
 	scheme  string
 	address string
-	client  *v2.SyncClient
+	client  syncClient
+	async   *v2.AsyncClient
+
+	mu      sync.Mutex     // Guards batch and serializes sends.
+	batch   []interface{}  // Events waiting to be sent.
+	lines   int            // Lines written to batch, which can hold more events when JSON arrays are split.
+	pending sync.WaitGroup // Async batches waiting for an ACK.
+	done    chan struct{}  // Closed to stop the flush loop.
+	stopped chan struct{}  // Closed when the flush loop has returned.
+
+	// The error has its own lock because async ACK callbacks run while a
+	// blocked send holds mu.
+	errMu  sync.Mutex
+	err    error // First error from a background flush or an async ACK.
+	failed int   // Lines in batches that failed to be sent.
 }
 
 // New returns a new lumberjack output.
@@ -45,6 +65,22 @@ func New(opts *output.Options) (output.Output, error) {
 		return nil, fmt.Errorf("failed to parse addr for lumberjack: %w", err)
 	}
 
+	switch opts.LumberjackOptions.Version {
+	case 0, 2:
+	case 1:
+		if opts.LumberjackOptions.Inflight > 1 {
+			return nil, errors.New("lumberjack protocol version 1 does not support more than one batch in flight")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported lumberjack protocol version %d (use 1 or 2)", opts.LumberjackOptions.Version)
+	}
+
+	// The batch options batch the events before they reach the output, so the
+	// output's own batching would only split or delay their batches.
+	if opts.BatchOptions.Enabled() && opts.LumberjackOptions.BatchSize > 1 {
+		return nil, errors.New("lumberjack batch size cannot be combined with the batch options, use one of them")
+	}
+
 	return &Output{
 		opts:    opts,
 		scheme:  scheme,
@@ -54,19 +90,18 @@ func New(opts *output.Options) (output.Output, error) {
 
 // DialContext connects to the configured endpoint.
 func (o *Output) DialContext(ctx context.Context) error {
-	var dialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+	dialContextFunc, err := o.opts.Dialer(&net.Dialer{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
 	switch o.scheme {
 	case "tcp":
-		dialer := &net.Dialer{Timeout: time.Second}
-		dialContextFunc = dialer.DialContext
 	case "tls":
-		dialer := &tls.Dialer{
-			Config: &tls.Config{
-				InsecureSkipVerify: o.opts.InsecureTLS,
-			},
-			NetDialer: &net.Dialer{Timeout: time.Second},
+		config, err := o.opts.TLSConfig()
+		if err != nil {
+			return err
 		}
-		dialContextFunc = dialer.DialContext
+		dialContextFunc = output.TLSDialer(dialContextFunc, config)
 	default:
 		panic("unhandled scheme " + o.scheme)
 	}
@@ -75,33 +110,207 @@ func (o *Output) DialContext(ctx context.Context) error {
 		return dialContextFunc(ctx, network, address)
 	}
 
-	client, err := v2.SyncDialWith(dial, o.address)
-	if err != nil {
-		return err
+	lopts := o.opts.LumberjackOptions
+	clientOpts := []v2.Option{v2.CompressionLevel(lopts.CompressionLevel)}
+	if lopts.Timeout > 0 {
+		clientOpts = append(clientOpts, v2.Timeout(lopts.Timeout))
+	}
+
+	switch {
+	case lopts.Version == 1:
+		conn, err := dialContextFunc(ctx, "tcp", o.address)
+		if err != nil {
+			return err
+		}
+		client, err := newV1Client(conn, lopts.CompressionLevel, lopts.Timeout)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		o.client = client
+	case lopts.Inflight > 1:
+		client, err := v2.AsyncDialWith(dial, o.address, lopts.Inflight, clientOpts...)
+		if err != nil {
+			return err
+		}
+		o.async = client
+	default:
+		client, err := v2.SyncDialWith(dial, o.address, clientOpts...)
+		if err != nil {
+			return err
+		}
+		o.client = client
+	}
+
+	if lopts.BatchSize > 1 && lopts.FlushInterval > 0 {
+		o.done = make(chan struct{})
+		o.stopped = make(chan struct{})
+		go o.flushLoop(lopts.FlushInterval)
 	}
 
-	o.client = client
 	return nil
 }
 
-// Close closes the connection to the configured endpoint.
+// Close sends any buffered events, waits for outstanding ACKs and closes the
+// connection to the configured endpoint.
 func (o *Output) Close() error {
-	if o.client != nil {
-		return o.client.Close()
+	if o.done != nil {
+		close(o.done)
+		<-o.stopped
+		o.done = nil
 	}
-	return nil
+
+	o.mu.Lock()
+	err := o.flush()
+	o.mu.Unlock()
+
+	switch {
+	case o.async != nil:
+		o.pending.Wait()
+		err = errors.Join(err, o.firstErr(), o.async.Close())
+	case o.client != nil:
+		err = errors.Join(err, o.firstErr(), o.client.Close())
+	}
+	return err
 }
 
-// Write writes data to the lumberjack output.
+// Write writes data to the lumberjack output. Events are buffered until the
+// configured batch size is reached.
 func (o *Output) Write(b []byte) (int, error) {
-	_, err := o.client.Send(makeBatch(b, o.opts.LumberjackOptions.ParseJSON))
-	if err != nil {
+	if o.client == nil && o.async == nil {
+		return 0, errors.New("not connected")
+	}
+
+	if err := o.firstErr(); err != nil {
+		o.addFailed(1)
 		return 0, err
 	}
 
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	lopts := o.opts.LumberjackOptions
+	for _, event := range makeBatch(b, lopts.ParseJSON) {
+		o.batch = append(o.batch, addMetadata(event, lopts))
+	}
+	o.lines++
+
+	if len(o.batch) >= max(lopts.BatchSize, 1) {
+		if err := o.flush(); err != nil {
+			return 0, err
+		}
+	}
+
 	return len(b), nil
 }
 
+// WriteBatch implements output.BatchOutput. The events in batch are added to
+// the buffered events, which are sent as one Lumberjack batch.
+func (o *Output) WriteBatch(batch [][]byte) error {
+	if o.client == nil && o.async == nil {
+		return errors.New("not connected")
+	}
+
+	if err := o.firstErr(); err != nil {
+		o.addFailed(len(batch))
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	lopts := o.opts.LumberjackOptions
+	for _, b := range batch {
+		for _, event := range makeBatch(b, lopts.ParseJSON) {
+			o.batch = append(o.batch, addMetadata(event, lopts))
+		}
+	}
+	o.lines += len(batch)
+	return o.flush()
+}
+
+// flush sends the buffered events. The caller must hold o.mu.
+func (o *Output) flush() error {
+	if len(o.batch) == 0 {
+		return nil
+	}
+
+	batch, lines := o.batch, o.lines
+	o.batch, o.lines = nil, 0
+
+	if o.async != nil {
+		o.pending.Add(1)
+		// A failed send also calls the callback with the error.
+		return o.async.Send(func(seq uint32, err error) {
+			defer o.pending.Done()
+			if err == nil && int(seq) < len(batch) {
+				err = fmt.Errorf("lumberjack server only acknowledged %d of %d events", seq, len(batch))
+			}
+			if err != nil {
+				o.setErr(err)
+				o.addFailed(lines)
+			}
+		}, batch)
+	}
+
+	if _, err := o.client.Send(batch); err != nil {
+		o.addFailed(lines)
+		return err
+	}
+	return nil
+}
+
+// flushLoop periodically sends partially filled batches.
+func (o *Output) flushLoop(interval time.Duration) {
+	defer close(o.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.done:
+			return
+		case <-ticker.C:
+			o.mu.Lock()
+			err := o.flush()
+			o.mu.Unlock()
+			if err != nil {
+				o.setErr(err)
+			}
+		}
+	}
+}
+
+func (o *Output) setErr(err error) {
+	o.errMu.Lock()
+	defer o.errMu.Unlock()
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+// addFailed counts n lines that could not be sent.
+func (o *Output) addFailed(n int) {
+	o.errMu.Lock()
+	defer o.errMu.Unlock()
+	o.failed += n
+}
+
+// FailedEvents implements output.BufferedOutput. A batch that the server only
+// partially acknowledged is counted in full.
+func (o *Output) FailedEvents() int {
+	o.errMu.Lock()
+	defer o.errMu.Unlock()
+	return o.failed
+}
+
+func (o *Output) firstErr() error {
+	o.errMu.Lock()
+	defer o.errMu.Unlock()
+	return o.err
+}
+
 func splitAddress(addr string) (scheme, host, port string, err error) {
 	// Use tcp:// scheme by default if not specified.
 	if !strings.Contains(addr, "://") {
@@ -163,3 +372,44 @@ func makeBatchFromJSON(b []byte) []interface{} {
 		data,
 	}
 }
+
+// addMetadata adds the Beats metadata configured in opts to event, in the same
+// shape that Beats would send it. Fields already present in the event are not
+// overwritten.
+func addMetadata(event interface{}, opts output.LumberjackOptions) interface{} {
+	m, ok := event.(map[string]interface{})
+	if !ok {
+		return event
+	}
+
+	if opts.Beat != "" {
+		meta := map[string]interface{}{
+			"beat": opts.Beat,
+			"type": "_doc",
+		}
+		agent := map[string]interface{}{
+			"type": opts.Beat,
+		}
+		if opts.BeatVersion != "" {
+			meta["version"] = opts.BeatVersion
+			agent["version"] = opts.BeatVersion
+		}
+		if opts.Hostname != "" {
+			agent["name"] = opts.Hostname
+		}
+		setDefault(m, "@metadata", meta)
+		setDefault(m, "agent", agent)
+	}
+
+	if opts.Hostname != "" {
+		setDefault(m, "host", map[string]interface{}{"name": opts.Hostname})
+	}
+
+	return m
+}
+
+func setDefault(m map[string]interface{}, key string, value interface{}) {
+	if _, found := m[key]; !found {
+		m[key] = value
+	}
+}
@@ -6,8 +6,10 @@ package lumberjack
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -141,3 +143,206 @@ func TestOutputWrite(t *testing.T) {
 	// Verify one message received.
 	assert.Len(t, messages, 1)
 }
+
+// startServer starts a lumberjack server on an ephemeral port. By default only
+// version 2 is accepted. The received batches are sent on the returned channel.
+func startServer(t *testing.T, opts ...server.Option) (addr string, batches <-chan []interface{}) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	opts = append([]server.Option{server.V1(false), server.V2(true)}, opts...)
+	s, err := server.NewWithListener(l, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	ch := make(chan []interface{}, 100)
+	go func() {
+		for batch := range s.ReceiveChan() {
+			ch <- batch.Events
+			batch.ACK()
+		}
+	}()
+
+	return l.Addr().String(), ch
+}
+
+func TestOutputWriteBatch(t *testing.T) {
+	addr, batches := startServer(t)
+
+	o, err := New(&output.Options{
+		Addr: addr,
+		LumberjackOptions: output.LumberjackOptions{
+			BatchSize: 3,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.DialContext(context.Background()))
+
+	for i := 0; i < 5; i++ {
+		_, err = o.Write([]byte("hello"))
+		require.NoError(t, err)
+	}
+	assert.Len(t, <-batches, 3)
+
+	// Close sends the remaining partial batch.
+	require.NoError(t, o.Close())
+	assert.Len(t, <-batches, 2)
+}
+
+func TestOutputWriteFlushInterval(t *testing.T) {
+	addr, batches := startServer(t)
+
+	o, err := New(&output.Options{
+		Addr: addr,
+		LumberjackOptions: output.LumberjackOptions{
+			BatchSize:     100,
+			FlushInterval: 10 * time.Millisecond,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.DialContext(context.Background()))
+	defer o.Close()
+
+	_, err = o.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	select {
+	case batch := <-batches:
+		assert.Len(t, batch, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("partial batch was not flushed")
+	}
+}
+
+func TestOutputWriteAsync(t *testing.T) {
+	addr, batches := startServer(t)
+
+	o, err := New(&output.Options{
+		Addr: addr,
+		LumberjackOptions: output.LumberjackOptions{
+			BatchSize:        2,
+			Inflight:         4,
+			CompressionLevel: 3,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.DialContext(context.Background()))
+
+	for i := 0; i < 10; i++ {
+		_, err = o.Write([]byte("hello"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, o.Close())
+
+	var total int
+	for total < 10 {
+		total += len(<-batches)
+	}
+	assert.Equal(t, 10, total)
+}
+
+func TestOutputWriteBatchAPI(t *testing.T) {
+	addr, batches := startServer(t)
+
+	o, err := New(&output.Options{Addr: addr})
+	require.NoError(t, err)
+	require.NoError(t, o.DialContext(context.Background()))
+	defer o.Close()
+
+	// All events are sent in one Lumberjack batch regardless of the batch size.
+	err = o.(output.BatchOutput).WriteBatch([][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	require.NoError(t, err)
+	assert.Len(t, <-batches, 3)
+}
+
+func TestOutputWriteV1(t *testing.T) {
+	for _, level := range []int{0, 3} {
+		t.Run(fmt.Sprintf("compression_level_%d", level), func(t *testing.T) {
+			addr, batches := startServer(t, server.V1(true), server.V2(false))
+
+			o, err := New(&output.Options{
+				Addr: addr,
+				LumberjackOptions: output.LumberjackOptions{
+					Version:          1,
+					ParseJSON:        true,
+					BatchSize:        2,
+					CompressionLevel: level,
+				},
+			})
+			require.NoError(t, err)
+			require.NoError(t, o.DialContext(context.Background()))
+
+			_, err = o.Write([]byte(`{"message":"hello","event":{"code":4624},"tags":["a"]}`))
+			require.NoError(t, err)
+			_, err = o.Write([]byte(`"world"`))
+			require.NoError(t, err)
+			require.NoError(t, o.Close())
+
+			// Version 1 events only contain strings, so nested fields are
+			// flattened and other values are JSON encoded.
+			assert.Equal(t, []interface{}{
+				map[string]string{"message": "hello", "event.code": "4624", "tags": `["a"]`},
+				map[string]string{"message": "world"},
+			}, <-batches)
+		})
+	}
+}
+
+func TestNewVersion(t *testing.T) {
+	_, err := New(&output.Options{
+		Addr:              "localhost:5044",
+		LumberjackOptions: output.LumberjackOptions{Version: 3},
+	})
+	assert.Error(t, err)
+
+	_, err = New(&output.Options{
+		Addr:              "localhost:5044",
+		LumberjackOptions: output.LumberjackOptions{Version: 1, Inflight: 2},
+	})
+	assert.Error(t, err)
+}
+
+func TestNewBatchOptions(t *testing.T) {
+	_, err := New(&output.Options{
+		Addr:              "localhost:5044",
+		BatchOptions:      output.BatchOptions{Count: 100},
+		LumberjackOptions: output.LumberjackOptions{BatchSize: 100},
+	})
+	assert.Error(t, err)
+
+	_, err = New(&output.Options{
+		Addr:              "localhost:5044",
+		BatchOptions:      output.BatchOptions{Count: 100},
+		LumberjackOptions: output.LumberjackOptions{BatchSize: 1},
+	})
+	assert.NoError(t, err)
+}
+
+func TestAddMetadata(t *testing.T) {
+	opts := output.LumberjackOptions{
+		Beat:        "filebeat",
+		BeatVersion: "8.15.0",
+		Hostname:    "host-1",
+	}
+
+	event := addMetadata(map[string]interface{}{"message": "hello", "host": "keep"}, opts)
+	assert.Equal(t, map[string]interface{}{
+		"message": "hello",
+		"host":    "keep",
+		"@metadata": map[string]interface{}{
+			"beat":    "filebeat",
+			"type":    "_doc",
+			"version": "8.15.0",
+		},
+		"agent": map[string]interface{}{
+			"type":    "filebeat",
+			"version": "8.15.0",
+			"name":    "host-1",
+		},
+	}, event)
+
+	// Non-object events are left as-is.
+	assert.Equal(t, "text", addMetadata("text", opts))
+}
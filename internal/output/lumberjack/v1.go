@@ -0,0 +1,188 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package lumberjack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"time"
+
+	protocol "github.com/elastic/go-lumber/protocol/v1"
+)
+
+// v1Client is a synchronous client for version 1 of the Lumberjack protocol,
+// as spoken by logstash-forwarder. Events are sent as data frames of string
+// key/value pairs. Nested fields are flattened into dotted keys, and values
+// that are not strings are JSON encoded.
+type v1Client struct {
+	conn             net.Conn
+	in               *bufio.Reader
+	compressionLevel int
+	timeout          time.Duration
+	buf              bytes.Buffer
+}
+
+func newV1Client(conn net.Conn, compressionLevel int, timeout time.Duration) (*v1Client, error) {
+	if compressionLevel < 0 || compressionLevel > 9 {
+		return nil, errors.New("compression level must be within 0 and 9")
+	}
+
+	return &v1Client{
+		conn:             conn,
+		in:               bufio.NewReader(conn),
+		compressionLevel: compressionLevel,
+		timeout:          timeout,
+	}, nil
+}
+
+// Send sends events as one window and blocks until they are ACKed.
+func (c *v1Client) Send(events []interface{}) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	c.buf.Reset()
+	c.buf.Write([]byte{protocol.CodeVersion, protocol.CodeWindowSize})
+	writeUint32(&c.buf, uint32(len(events)))
+
+	if c.compressionLevel > 0 {
+		var frames bytes.Buffer
+		writeDataFrames(&frames, events)
+
+		var compressed bytes.Buffer
+		w, err := zlib.NewWriterLevel(&compressed, c.compressionLevel)
+		if err != nil {
+			return 0, err
+		}
+		if _, err = w.Write(frames.Bytes()); err != nil {
+			return 0, err
+		}
+		if err = w.Close(); err != nil {
+			return 0, err
+		}
+
+		c.buf.Write([]byte{protocol.CodeVersion, protocol.CodeCompressed})
+		writeUint32(&c.buf, uint32(compressed.Len()))
+		c.buf.Write(compressed.Bytes())
+	} else {
+		writeDataFrames(&c.buf, events)
+	}
+
+	if err := c.setDeadline(); err != nil {
+		return 0, err
+	}
+	if _, err := c.conn.Write(c.buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	// The server may ACK a window in several parts.
+	for {
+		seq, err := c.readACK()
+		if err != nil {
+			return 0, err
+		}
+		if int(seq) >= len(events) {
+			return len(events), nil
+		}
+	}
+}
+
+func (c *v1Client) readACK() (uint32, error) {
+	if err := c.setDeadline(); err != nil {
+		return 0, err
+	}
+
+	var ack [6]byte
+	if _, err := io.ReadFull(c.in, ack[:]); err != nil {
+		return 0, err
+	}
+	if ack[0] != protocol.CodeVersion || ack[1] != protocol.CodeACK {
+		return 0, fmt.Errorf("lumberjack v1 protocol error: expected ACK frame, got %q", ack[:2])
+	}
+	return binary.BigEndian.Uint32(ack[2:]), nil
+}
+
+func (c *v1Client) setDeadline() error {
+	if c.timeout <= 0 {
+		return nil
+	}
+	return c.conn.SetDeadline(time.Now().Add(c.timeout))
+}
+
+// Close closes the underlying connection.
+func (c *v1Client) Close() error {
+	return c.conn.Close()
+}
+
+// writeDataFrames writes one data frame per event, with sequence numbers
+// starting at 1.
+func writeDataFrames(w *bytes.Buffer, events []interface{}) {
+	for i, event := range events {
+		fields := map[string]string{}
+		flatten(fields, "", event)
+
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		w.Write([]byte{protocol.CodeVersion, protocol.CodeDataFrame})
+		writeUint32(w, uint32(i+1))
+		writeUint32(w, uint32(len(keys)))
+		for _, k := range keys {
+			writeString(w, k)
+			writeString(w, fields[k])
+		}
+	}
+}
+
+// flatten converts v into string key/value pairs. Objects are flattened into
+// dotted keys. An event that is not an object is stored in the message field.
+func flatten(fields map[string]string, prefix string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if prefix != "" {
+				k = prefix + "." + k
+			}
+			flatten(fields, k, child)
+		}
+		return
+	case string:
+		if prefix == "" {
+			prefix = "message"
+		}
+		fields[prefix] = v
+	default:
+		if prefix == "" {
+			prefix = "message"
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			raw = []byte(fmt.Sprint(v))
+		}
+		fields[prefix] = string(raw)
+	}
+}
+
+func writeString(w *bytes.Buffer, s string) {
+	writeUint32(w, uint32(len(s)))
+	w.WriteString(s)
+}
+
+func writeUint32(w *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.Write(b[:])
+}
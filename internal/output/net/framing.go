@@ -0,0 +1,69 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package netout
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Framing methods for stream-oriented protocols.
+const (
+	FramingDelimiter     = "delimiter"       // Event followed by a delimiter.
+	FramingOctetCounting = "octet-counting"  // RFC 6587 octet-counting (e.g. "11 hello world").
+	FramingLength2       = "length-prefix-2" // Event preceded by its length as a 2-byte big-endian integer.
+	FramingLength4       = "length-prefix-4" // Event preceded by its length as a 4-byte big-endian integer.
+	FramingNone          = "none"            // Event written as-is.
+)
+
+// framer appends the framed event b to dst.
+type framer func(dst, b []byte) ([]byte, error)
+
+// newFramer returns the framer for the framing method. The delimiter may
+// contain Go escape sequences such as \n, \r\n or \x00.
+func newFramer(framing, delimiter string) (framer, error) {
+	switch framing {
+	case "", FramingDelimiter:
+		delim := "\n"
+		if delimiter != "" {
+			var err error
+			delim, err = strconv.Unquote(`"` + delimiter + `"`)
+			if err != nil {
+				return nil, fmt.Errorf("invalid delimiter %q: %w", delimiter, err)
+			}
+		}
+		return func(dst, b []byte) ([]byte, error) {
+			return append(append(dst, b...), delim...), nil
+		}, nil
+	case FramingOctetCounting:
+		return func(dst, b []byte) ([]byte, error) {
+			dst = strconv.AppendInt(dst, int64(len(b)), 10)
+			return append(append(dst, ' '), b...), nil
+		}, nil
+	case FramingLength2:
+		return func(dst, b []byte) ([]byte, error) {
+			if len(b) > math.MaxUint16 {
+				return nil, fmt.Errorf("event of %d bytes is too large for a 2-byte length prefix", len(b))
+			}
+			return append(binary.BigEndian.AppendUint16(dst, uint16(len(b))), b...), nil
+		}, nil
+	case FramingLength4:
+		return func(dst, b []byte) ([]byte, error) {
+			if uint64(len(b)) > math.MaxUint32 {
+				return nil, fmt.Errorf("event of %d bytes is too large for a 4-byte length prefix", len(b))
+			}
+			return append(binary.BigEndian.AppendUint32(dst, uint32(len(b))), b...), nil
+		}, nil
+	case FramingNone:
+		return func(dst, b []byte) ([]byte, error) {
+			return append(dst, b...), nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown framing %q (use %s, %s, %s, %s or %s)", framing,
+			FramingDelimiter, FramingOctetCounting, FramingLength2, FramingLength4, FramingNone)
+	}
+}
@@ -2,7 +2,8 @@
 // Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
 // See the LICENSE file in the project root for more information.
 
-// Package netout provides a unified network output supporting tcp, tls, udp, and unix protocols.
+// Package netout provides a unified network output supporting tcp, tls, udp,
+// unix, unixgram and unixpacket protocols.
 package netout
 
 import (
@@ -26,6 +27,18 @@ func init() {
 	output.Register("tls", New)
 	output.Register("udp", New)
 	output.Register("unix", New)
+	output.Register("unixgram", New)
+	output.Register("unixpacket", New)
+}
+
+// isMessageOriented returns true for protocols that preserve message
+// boundaries. Each event is written as one datagram or packet without framing.
+func isMessageOriented(protocol string) bool {
+	switch protocol {
+	case "udp", "unixgram", "unixpacket":
+		return true
+	}
+	return false
 }
 
 // Output holds options and the active connection.
@@ -34,14 +47,23 @@ type Output struct {
 	conn  net.Conn
 	ctx   context.Context
 	limit *rate.Limiter
+	frame framer
+	buf   []byte
 }
 
 // New creates a new network output for the protocol specified in opts.Protocol.
 func New(opts *output.Options) (output.Output, error) {
 	o := &Output{opts: opts}
-	if opts.Protocol == "udp" {
+	if isMessageOriented(opts.Protocol) {
 		o.limit = rate.NewLimiter(rate.Limit(opts.RateLimit), burst)
+		return o, nil
 	}
+
+	frame, err := newFramer(opts.NetOptions.Framing, opts.NetOptions.Delimiter)
+	if err != nil {
+		return nil, err
+	}
+	o.frame = frame
 	return o, nil
 }
 
@@ -54,16 +76,32 @@ func (o *Output) DialContext(ctx context.Context) error {
 
 	switch o.opts.Protocol {
 	case "tls":
-		d := tls.Dialer{
-			Config:    &tls.Config{InsecureSkipVerify: o.opts.InsecureTLS}, //nolint:gosec
-			NetDialer: &net.Dialer{Timeout: time.Second},
+		var config *tls.Config
+		config, err = o.opts.TLSConfig()
+		if err != nil {
+			return err
+		}
+		var dial output.DialContextFunc
+		dial, err = o.opts.Dialer(&net.Dialer{Timeout: time.Second})
+		if err != nil {
+			return err
 		}
-		conn, err = d.DialContext(ctx, "tcp", o.opts.Addr)
+		conn, err = output.TLSDialer(dial, config)(ctx, "tcp", o.opts.Addr)
 	case "udp":
 		conn, err = net.Dial("udp", o.opts.Addr)
 		o.ctx = ctx
-	case "tcp", "unix":
-
+	case "unixgram", "unixpacket":
+		d := net.Dialer{Timeout: time.Second}
+		conn, err = d.DialContext(ctx, o.opts.Protocol, o.opts.Addr)
+		o.ctx = ctx
+	case "tcp":
+		var dial output.DialContextFunc
+		dial, err = o.opts.Dialer(&net.Dialer{Timeout: time.Second})
+		if err != nil {
+			return err
+		}
+		conn, err = dial(ctx, "tcp", o.opts.Addr)
+	case "unix":
 		d := net.Dialer{Timeout: time.Second}
 		conn, err = d.DialContext(ctx, o.opts.Protocol, o.opts.Addr)
 	default:
@@ -84,7 +122,7 @@ func (o *Output) Close() error {
 		return nil
 	}
 
-	if o.opts.Protocol == "udp" {
+	if isMessageOriented(o.opts.Protocol) {
 		return o.conn.Close()
 	}
 
@@ -116,19 +154,29 @@ func (o *Output) Close() error {
 	return o.conn.Close()
 }
 
-// Write writes b to the connection. A newline is appended for stream-oriented
-// protocols (tcp, tls, unix); UDP datagrams are written as-is.
+// Write writes b to the connection. For stream-oriented protocols (tcp, tls,
+// unix) b is framed according to the framing option, which appends a newline by
+// default. For udp, unixgram and unixpacket b is written as-is in one datagram
+// or packet, subject to the rate limit.
 func (o *Output) Write(b []byte) (int, error) {
 	if o.conn == nil {
 		return 0, errors.New("not connected")
 	}
 
-	if o.opts.Protocol == "udp" {
+	if isMessageOriented(o.opts.Protocol) {
 		if err := o.limit.WaitN(o.ctx, len(b)); err != nil {
 			return 0, err
 		}
 		return o.conn.Write(b)
 	}
 
-	return o.conn.Write(append(b[:len(b):len(b)], '\n'))
+	buf, err := o.frame(o.buf[:0], b)
+	if err != nil {
+		return 0, err
+	}
+	o.buf = buf
+	if _, err = o.conn.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
 }
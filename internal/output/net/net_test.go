@@ -9,6 +9,7 @@ import (
 	"net"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -102,6 +103,52 @@ func TestTCPWrite(t *testing.T) {
 	assert.Equal(t, byte(0xff), backing[len(msg)], "Write modified the input buffer")
 }
 
+func TestTCPWriteFraming(t *testing.T) {
+	testCases := []struct {
+		framing   string
+		delimiter string
+		want      []byte
+	}{
+		{framing: "delimiter", want: []byte("a\nb\n")},
+		{framing: "delimiter", delimiter: `\x00`, want: []byte("a\nb\x00")},
+		{framing: "delimiter", delimiter: `\r\n`, want: []byte("a\nb\r\n")},
+		{framing: "octet-counting", want: []byte("3 a\nb")},
+		{framing: "length-prefix-2", want: []byte("\x00\x03a\nb")},
+		{framing: "length-prefix-4", want: []byte("\x00\x00\x00\x03a\nb")},
+		{framing: "none", want: []byte("a\nb")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.framing+tc.delimiter, func(t *testing.T) {
+			l := newTCPListener(t)
+			ch := make(chan []byte, 1)
+			go acceptAndCollect(l, ch)
+
+			out, err := New(&output.Options{
+				Protocol:   "tcp",
+				Addr:       l.Addr().String(),
+				NetOptions: output.NetOptions{Framing: tc.framing, Delimiter: tc.delimiter},
+			})
+			require.NoError(t, err)
+			require.NoError(t, out.DialContext(context.Background()))
+
+			// Events with embedded newlines stay intact.
+			n, err := out.Write([]byte("a\nb"))
+			require.NoError(t, err)
+			assert.Equal(t, 3, n)
+			assert.Equal(t, tc.want, <-ch)
+		})
+	}
+}
+
+func TestNewInvalidFraming(t *testing.T) {
+	_, err := New(&output.Options{Protocol: "tcp", NetOptions: output.NetOptions{Framing: "foo"}})
+	assert.Error(t, err)
+
+	_, err = New(&output.Options{Protocol: "tcp", NetOptions: output.NetOptions{Delimiter: `\q`}})
+	assert.Error(t, err)
+}
+
 func TestTCPCloseWithoutDial(t *testing.T) {
 	out, err := New(&output.Options{Protocol: "tcp", Addr: "127.0.0.1:1"})
 	require.NoError(t, err)
@@ -195,6 +242,106 @@ func TestUDPWrite(t *testing.T) {
 	assert.Equal(t, msg, buf[:n])
 }
 
+// Unix datagram and packet tests
+
+func TestUnixgramWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skipf("unixgram not supported: %v", err)
+	}
+	defer pc.Close()
+
+	out, err := New(&output.Options{Protocol: "unixgram", Addr: path, RateLimit: 1024 * 1024})
+	require.NoError(t, err)
+	require.NoError(t, out.DialContext(context.Background()))
+	defer out.Close()
+
+	for _, msg := range []string{"hello", "unixgram"} {
+		_, err = out.Write([]byte(msg))
+		require.NoError(t, err)
+	}
+
+	// Each event is one datagram without a newline.
+	buf := make([]byte, 4096)
+	for _, want := range []string{"hello", "unixgram"} {
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(buf[:n]))
+	}
+}
+
+func TestUnixpacketWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	l, err := net.Listen("unixpacket", path)
+	if err != nil {
+		t.Skipf("unixpacket not supported: %v", err)
+	}
+	defer l.Close()
+
+	ch := make(chan []byte, 2)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			buf := make([]byte, 4096)
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			ch <- buf[:n]
+		}
+	}()
+
+	out, err := New(&output.Options{Protocol: "unixpacket", Addr: path, RateLimit: 1024 * 1024})
+	require.NoError(t, err)
+	require.NoError(t, out.DialContext(context.Background()))
+
+	for _, msg := range []string{"hello", "unixpacket"} {
+		_, err = out.Write([]byte(msg))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []byte("hello"), <-ch)
+	assert.Equal(t, []byte("unixpacket"), <-ch)
+	require.NoError(t, out.Close())
+}
+
+func TestUnixgramRateLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skipf("unixgram not supported: %v", err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			if _, _, err := pc.ReadFrom(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	out, err := New(&output.Options{Protocol: "unixgram", Addr: path, RateLimit: 10})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, out.DialContext(ctx))
+	defer out.Close()
+
+	// Use up the initial burst. Writing 100 bytes more at 10 bytes/sec can't
+	// complete before the context is done.
+	for i := 0; i < burst/4096; i++ {
+		_, err = out.Write(make([]byte, 4096))
+		require.NoError(t, err)
+	}
+	_, err = out.Write(make([]byte, 100))
+	assert.Error(t, err)
+}
+
 // Unknown protocol test
 
 func TestDialUnknownProtocol(t *testing.T) {
@@ -208,7 +355,7 @@ func TestDialUnknownProtocol(t *testing.T) {
 
 func TestRegistered(t *testing.T) {
 	available := output.Available()
-	for _, proto := range []string{"tcp", "tls", "udp", "unix"} {
+	for _, proto := range []string{"tcp", "tls", "udp", "unix", "unixgram", "unixpacket"} {
 		assert.Contains(t, available, proto, "protocol %q should be registered", proto)
 	}
 }
@@ -4,79 +4,240 @@
 
 package output
 
-import "time"
+import (
+	"reflect"
+	"time"
+)
 
 // Options holds the configuration for an output.
 type Options struct {
-	Addr           string        // Destination address (host:port).
-	Delay          time.Duration // Delay start after start signal.
-	Protocol       string        // Protocol (udp/tcp/tls).
-	Retries        int           // Number of connection retries for tcp based protocols.
-	StartSignal    string        // OS signal to wait on before starting.
-	InsecureTLS    bool          // Disable TLS verification checks.
-	RateLimit      int           // UDP rate limit in bytes.
-	MaxLogLineSize int           // Log reader buffer size in bytes.
-
-	WebhookOptions
-	GCPPubsubOptions
-	KafkaOptions
-	AzureBlobStorageOptions
-	AzureEventHubOptions
-	LumberjackOptions
-	GCSOptions
+	Addr           string         `config:"addr"`              // Destination address (host:port).
+	Delay          time.Duration  `config:"delay"`             // Delay start after start signal.
+	Protocol       string         `config:"protocol"`          // Protocol (udp/tcp/tls).
+	Retries        int            `config:"retries"`           // Number of dial attempts for tcp based protocols (at least one).
+	StartSignal    string         `config:"start_signal"`      // OS signal to wait on before starting.
+	InsecureTLS    bool           `config:"insecure_tls"`      // Disable TLS verification checks.
+	RateLimit      int            `config:"rate_limit"`        // UDP, unixgram and unixpacket rate limit in bytes.
+	MaxLogLineSize int            `config:"max_log_line_size"` // Log reader buffer size in bytes.
+	Proxy          string         `config:"proxy"`             // Proxy URL (http, https or socks5).
+	Fanout         []FanoutOutput `config:",ignore"`           // Outputs that events are written to instead of Protocol.
+
+	TLSOptions              `config:"tls"`
+	NetOptions              `config:"net"`
+	ReconnectOptions        `config:"reconnect"`
+	RateLimitOptions        `config:"rate"`
+	WorkerOptions           `config:"worker"`
+	BatchOptions            `config:"batch"`
+	WebhookOptions          `config:"webhook"`
+	GCPPubsubOptions        `config:"gcppubsub"`
+	KafkaOptions            `config:"kafka"`
+	AzureBlobStorageOptions `config:"azure_blob_storage"`
+	AzureEventHubOptions    `config:"azure_event_hub"`
+	LumberjackOptions       `config:"lumberjack"`
+	GCSOptions              `config:"gcs"`
+	WebSocketOptions        `config:"websocket"`
+	GRPCOptions             `config:"grpc"`
+}
+
+// NetOptions holds configuration for the stream-oriented net outputs (tcp, tls
+// and unix).
+type NetOptions struct {
+	Framing   string `config:"framing"`   // Framing method (delimiter, octet-counting, length-prefix-2, length-prefix-4 or none).
+	Delimiter string `config:"delimiter"` // Delimiter used by delimiter framing. Supports escapes such as \n, \r\n and \x00.
 }
 
 // WebhookOptions holds configuration for the webhook output.
 type WebhookOptions struct {
-	ContentType string        // Content-Type header.
-	Headers     []string      // Headers in Key=Value format.
-	Username    string        // Basic auth username.
-	Password    string        // Basic auth password.
-	Timeout     time.Duration // Timeout for request handling.
-	Probe       string        // Server probe behavior.
+	ContentType string        `config:"content_type"` // Content-Type header.
+	Headers     []string      `config:"headers"`      // Headers in Key=Value format.
+	Username    string        `config:"username"`     // Basic auth username.
+	Password    string        `config:"password"`     // Basic auth password.
+	Timeout     time.Duration `config:"timeout"`      // Timeout for request handling.
+	Probe       string        `config:"probe"`        // Server probe behavior.
+
+	BodyTemplate    string        `config:"body_template"`     // Go template that wraps each event. The event is available as dot.
+	HeaderTemplates []string      `config:"header_templates"`  // Headers in Key=Template format, executed with the request body.
+	Method          string        `config:"method"`            // HTTP method of requests. Defaults to POST.
+	HTTPVersion     string        `config:"http_version"`      // HTTP version (auto, 1.1, 2 or h2c). Auto negotiates HTTP/2 over TLS.
+	MaxConns        int           `config:"max_conns"`         // Maximum connections to the endpoint. Zero is no limit.
+	BatchFormat     string        `config:"batch_format"`      // Body format of batched events (ndjson or json-array). Defaults to ndjson.
+	Compression     string        `config:"compression"`       // Request body compression (none, gzip, deflate or zstd).
+	AcceptStatus    []string      `config:"accept_status"`     // Accepted response status codes (e.g. 2xx, 202). Defaults to 2xx.
+	RetryMax        int           `config:"retry_max"`         // Retries of requests answered with 429 or 503.
+	RetryBackoff    time.Duration `config:"retry_backoff"`     // Wait before the first retry. It doubles with each retry.
+	RetryMaxBackoff time.Duration `config:"retry_max_backoff"` // Maximum wait between retries, including waits from Retry-After.
+
+	ResponseFile    string   `config:"response_file"`     // JSONL file that responses are appended to.
+	ExpectStatus    []string `config:"expect_status"`     // Status codes every response must have (e.g. 2xx, 202).
+	ExpectBodyRegex string   `config:"expect_body_regex"` // Regular expression every response body must match.
+	ExpectJSONPath  []string `config:"expect_json_path"`  // JSONPath assertions on response bodies in Path=Value or Path format.
+
+	BearerToken     string `config:"bearer_token"`      // Bearer token sent in the Authorization header.
+	BearerTokenFile string `config:"bearer_token_file"` // File containing the bearer token.
+
+	OAuth2TokenURL       string   `config:"oauth2_token_url"`       // OAuth2 client credentials token endpoint.
+	OAuth2ClientID       string   `config:"oauth2_client_id"`       // OAuth2 client ID.
+	OAuth2ClientSecret   string   `config:"oauth2_client_secret"`   // OAuth2 client secret.
+	OAuth2Scopes         []string `config:"oauth2_scopes"`          // OAuth2 scopes to request.
+	OAuth2EndpointParams []string `config:"oauth2_endpoint_params"` // Extra token request parameters in Key=Value format.
+
+	HMACSecret   string `config:"hmac_secret"`   // Secret used to sign the request body with HMAC-SHA256.
+	HMACHeader   string `config:"hmac_header"`   // Header containing the signature. Defaults to X-Hub-Signature-256.
+	HMACPrefix   string `config:"hmac_prefix"`   // Prefix prepended to the signature (e.g. sha256=).
+	HMACEncoding string `config:"hmac_encoding"` // Signature encoding (hex or base64). Defaults to hex.
+
+	AWSRegion          string `config:"aws_region"`            // AWS region. Setting it enables AWS SigV4 signing.
+	AWSService         string `config:"aws_service"`           // AWS service name used for signing. Defaults to execute-api.
+	AWSAccessKeyID     string `config:"aws_access_key_id"`     // AWS access key ID. Defaults to AWS_ACCESS_KEY_ID.
+	AWSSecretAccessKey string `config:"aws_secret_access_key"` // AWS secret access key. Defaults to AWS_SECRET_ACCESS_KEY.
+	AWSSessionToken    string `config:"aws_session_token"`     // AWS session token. Defaults to AWS_SESSION_TOKEN.
 }
 
 // GCPPubsubOptions holds configuration for the Google Cloud Pub/Sub output.
 type GCPPubsubOptions struct {
-	Project      string // Project is the Google Cloud project name.
-	Topic        string // Topic is the Pub/Sub topic name. The topic will be created if it does not exist.
-	Subscription string // Subscription is the Pub/Sub subscription name. The subscription will be created if it does not exist.
-	Clear        bool   // Clear removes all topics and subscriptions before running.
+	Project          string        `config:"project"`            // Project is the Google Cloud project name.
+	Topic            string        `config:"topic"`              // Topic is the Pub/Sub topic name. The emulator creates it if it does not exist.
+	Subscription     string        `config:"subscription"`       // Subscription is the Pub/Sub subscription name. The emulator creates it if it does not exist.
+	Clear            bool          `config:"clear"`              // Clear removes all topics and subscriptions before running. Only applies to the emulator.
+	Attributes       []string      `config:"attributes"`         // Attributes are message attributes in Key=Value format.
+	AttributeFields  []string      `config:"attribute_fields"`   // AttributeFields are message attributes taken from JSON fields in Key=field.path format.
+	OrderingKey      string        `config:"ordering_key"`       // OrderingKey is the ordering key set on every message.
+	OrderingKeyField string        `config:"ordering_key_field"` // OrderingKeyField is the dotted path of a JSON field whose value is used as the ordering key.
+	Async            bool          `config:"async"`              // Async publishes messages without waiting for each one to be acknowledged.
+	BatchCount       int           `config:"batch_count"`        // BatchCount publishes a batch when it has this many messages.
+	BatchBytes       int           `config:"batch_bytes"`        // BatchBytes publishes a batch when its size reaches this many bytes.
+	BatchDelay       time.Duration `config:"batch_delay"`        // BatchDelay publishes a non-empty batch after this delay.
 }
 
 // KafkaOptions holds configuration for the Kafka output.
 type KafkaOptions struct {
-	Topic string // Topic is the Kafka topic name. The topic will be created if it does not exist.
+	Topic             string   `config:"topic"`              // Topic is the Kafka topic name. The topic will be created if it does not exist.
+	Partitions        int32    `config:"partitions"`         // Partitions is the number of partitions used when creating the topic.
+	ReplicationFactor int16    `config:"replication_factor"` // ReplicationFactor is the replication factor used when creating the topic.
+	Partitioner       string   `config:"partitioner"`        // Partitioner selects the partitioner (hash, random, roundrobin).
+	KeyField          string   `config:"key_field"`          // KeyField is the dotted path of a JSON field whose value is used as the message key.
+	KeyTemplate       string   `config:"key_template"`       // KeyTemplate is a Go template evaluated against the JSON event to produce the message key.
+	Headers           []string `config:"headers"`            // Headers are record headers in Key=Value format.
+	Compression       string   `config:"compression"`        // Compression is the compression codec (none, gzip, snappy, lz4, zstd).
+	Async             bool     `config:"async"`              // Async uses an asynchronous producer that does not wait for each message to be acknowledged.
+	TLS               bool     `config:"tls"`                // TLS enables TLS when connecting to the brokers.
+	SASLMechanism     string   `config:"sasl_mechanism"`     // SASLMechanism enables SASL authentication (PLAIN, SCRAM-SHA-256, SCRAM-SHA-512, OAUTHBEARER).
+	Username          string   `config:"username"`           // Username is the SASL username.
+	Password          string   `config:"password"`           // Password is the SASL password.
+	OAuthToken        string   `config:"oauth_token"`        // OAuthToken is the SASL/OAUTHBEARER access token.
 }
 
 // AzureBlobStorageOptions holds configuration for the Azure Blob Storage output.
 type AzureBlobStorageOptions struct {
-	Container string // Container is the container name. The container will be created if it does not exist.
-	Blob      string // Blob is the blob name to use. The blob will be created inside the container.
-	Port      string // Port is the port number used for tests to update the connection string.
+	Container string `config:"container"` // Container is the container name. The container will be created if it does not exist.
+	Blob      string `config:"blob"`      // Blob is the blob name to use. The blob will be created inside the container.
+	Port      string `config:"port"`      // Port is the port number used for tests to update the connection string.
 }
 
 // AzureEventHubOptions holds configuration for the Azure Event Hub output.
 type AzureEventHubOptions struct {
-	FullyQualifiedNamespace string // FullyQualifiedNamespace is the Event Hubs namespace name (e.g. myeventhub.servicebus.windows.net).
-	EventHubName            string // EventHubName is the name of the Event Hub.
-	ConnectionString        string // ConnectionString is the connection string to connect to the Event Hub.
+	FullyQualifiedNamespace string `config:"fully_qualified_namespace"` // FullyQualifiedNamespace is the Event Hubs namespace name (e.g. myeventhub.servicebus.windows.net).
+	EventHubName            string `config:"event_hub_name"`            // EventHubName is the name of the Event Hub.
+	ConnectionString        string `config:"connection_string"`         // ConnectionString is the connection string to connect to the Event Hub.
 }
 
 // LumberjackOptions holds configuration for the Lumberjack output.
 type LumberjackOptions struct {
 	// ParseJSON parses the input bytes as JSON and sends structured data. By default, input bytes are sent in a 'message' field.
-	ParseJSON bool
+	ParseJSON bool `config:"parse_json"`
+	// Version is the Lumberjack protocol version (1 or 2).
+	Version int `config:"version"`
+	// BatchSize is the number of events sent in each batch.
+	BatchSize int `config:"batch_size"`
+	// FlushInterval is the maximum time events are buffered before a partial batch is sent.
+	FlushInterval time.Duration `config:"flush_interval"`
+	// Inflight is the number of batches that may be waiting for an ACK. Values greater than one use an async client.
+	Inflight int `config:"inflight"`
+	// CompressionLevel is the zlib compression level (0 to 9). Zero disables compression.
+	CompressionLevel int `config:"compression_level"`
+	// Timeout is the network read/write timeout.
+	Timeout time.Duration `config:"timeout"`
+	// Beat is the Beat name added to events as @metadata.beat and agent.type.
+	Beat string `config:"beat"`
+	// BeatVersion is the Beat version added to events as @metadata.version and agent.version.
+	BeatVersion string `config:"beat_version"`
+	// Hostname is added to events as host.name and agent.name.
+	Hostname string `config:"hostname"`
 }
 
 // GCSOptions holds configuration for the Google Cloud Storage output.
 type GCSOptions struct {
 	// ProjectID is the Google Cloud project ID.
-	ProjectID string
+	ProjectID string `config:"project_id"`
 	// ObjectContentType is the content-type set for the object that is created in the bucket. Defaults to application/json.
-	ObjectContentType string
+	ObjectContentType string `config:"object_content_type"`
 	// Bucket is the bucket name. The bucket will be created if it does not exist.
-	Bucket string
-	// Object is the name of the object created inside the related bucket.
-	Object string
+	Bucket string `config:"bucket"`
+	// Object is the name of the object created inside the related bucket. It is
+	// a Go template that can reference .Seq, .Source, .Time and .Date.
+	Object string `config:"object"`
+	// ObjectPerFile writes the data read from each input file to its own object.
+	ObjectPerFile bool `config:"object_per_file"`
+	// RotateBytes starts a new object once the current one holds this many bytes.
+	RotateBytes int64 `config:"rotate_bytes"`
+	// RotateLines starts a new object once the current one holds this many lines.
+	RotateLines int `config:"rotate_lines"`
+	// Gzip compresses objects and sets their Content-Encoding to gzip.
+	Gzip bool `config:"gzip"`
+	// Metadata is custom object metadata in Key=Value format.
+	Metadata []string `config:"metadata"`
+	// FailIfExists fails instead of overwriting objects that already exist.
+	FailIfExists bool `config:"fail_if_exists"`
+}
+
+// WebSocketOptions holds configuration for the WebSocket output. Headers and
+// TLS settings are shared with the webhook output.
+type WebSocketOptions struct {
+	Subprotocols     []string      `config:"subprotocols"`      // Subprotocols offered in the opening handshake.
+	MessageType      string        `config:"message_type"`      // Message type (text or binary). Defaults to text.
+	AckPattern       string        `config:"ack_pattern"`       // Regular expression that a server message must match after each sent message.
+	AckTimeout       time.Duration `config:"ack_timeout"`       // Time to wait for an ack. Zero waits forever.
+	HandshakeTimeout time.Duration `config:"handshake_timeout"` // Time to wait for the opening handshake. Zero uses the default.
+}
+
+// GRPCOptions holds configuration for the gRPC output.
+type GRPCOptions struct {
+	DescriptorSet  string        `config:"descriptor_set"`  // Path of a FileDescriptorSet that contains the service (protoc --include_imports --descriptor_set_out).
+	Method         string        `config:"method"`          // Full method name (e.g. package.Service/Method).
+	RequestField   string        `config:"request_field"`   // Request field that each event is decoded into. Empty decodes events into the request itself.
+	DiscardUnknown bool          `config:"discard_unknown"` // Ignore JSON fields that are not in the message.
+	Metadata       []string      `config:"metadata"`        // Metadata headers in Key=Value format.
+	TLS            bool          `config:"tls"`             // Connect using TLS.
+	Timeout        time.Duration `config:"timeout"`         // Timeout of unary calls. Zero is no timeout.
+	DialTimeout    time.Duration `config:"dial_timeout"`    // Time to wait for the connection to be ready. Zero uses the default.
+}
+
+// Clone returns a deep copy of o, so that changes to the copy, such as
+// unpacking a configuration into it, don't affect o.
+func (o *Options) Clone() *Options {
+	c := *o
+	cloneStringSlices(reflect.ValueOf(&c).Elem())
+	if o.Fanout != nil {
+		c.Fanout = make([]FanoutOutput, len(o.Fanout))
+		for i, fo := range o.Fanout {
+			fo.Options = fo.Options.Clone()
+			c.Fanout[i] = fo
+		}
+	}
+	return &c
+}
+
+// cloneStringSlices replaces the string slices of the struct v, including
+// those of its nested structs, with copies.
+func cloneStringSlices(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch {
+		case !f.CanSet():
+		case f.Kind() == reflect.Struct:
+			cloneStringSlices(f)
+		case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String && !f.IsNil():
+			f.Set(reflect.AppendSlice(reflect.MakeSlice(f.Type(), 0, f.Len()), f))
+		}
+	}
 }
@@ -0,0 +1,31 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsClone(t *testing.T) {
+	opts := &Options{
+		Protocol:       "webhook",
+		WebhookOptions: WebhookOptions{Headers: []string{"a=1"}},
+		Fanout: []FanoutOutput{
+			{Options: &Options{KafkaOptions: KafkaOptions{Headers: []string{"b=2"}}}, Policy: "best-effort"},
+		},
+	}
+
+	c := opts.Clone()
+	assert.Equal(t, opts, c)
+
+	c.WebhookOptions.Headers[0] = "a=2"
+	c.Fanout[0].Options.KafkaOptions.Headers[0] = "b=3"
+	c.Fanout[0].Policy = "fail-fast"
+	assert.Equal(t, []string{"a=1"}, opts.WebhookOptions.Headers)
+	assert.Equal(t, []string{"b=2"}, opts.Fanout[0].Options.KafkaOptions.Headers)
+	assert.Equal(t, "best-effort", opts.Fanout[0].Policy)
+}
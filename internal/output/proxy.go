@@ -0,0 +1,198 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// DialContextFunc connects to the address on the named network.
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Dial implements golang.org/x/net/proxy.Dialer.
+func (f DialContextFunc) Dial(network, address string) (net.Conn, error) {
+	return f(context.Background(), network, address)
+}
+
+// DialContext implements golang.org/x/net/proxy.ContextDialer.
+func (f DialContextFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+// ProxyURL returns the parsed proxy URL, or nil if no proxy is configured.
+func (o *Options) ProxyURL() (*url.URL, error) {
+	if o.Proxy == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(o.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy url scheme %q (use http, https or socks5)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy url %q has no host", o.Proxy)
+	}
+	return u, nil
+}
+
+// Dialer returns a function that connects using d, through the proxy if one is
+// configured. Only tcp connections can be proxied.
+func (o *Options) Dialer(d *net.Dialer) (DialContextFunc, error) {
+	u, err := o.ProxyURL()
+	if err != nil || u == nil {
+		return d.DialContext, err
+	}
+
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		socks, err := proxy.SOCKS5("tcp", u.Host, auth, d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create socks5 proxy dialer: %w", err)
+		}
+		return socks.(proxy.ContextDialer).DialContext, nil
+	default:
+		return connectDialer(u, d), nil
+	}
+}
+
+// connectDialer returns a function that connects through an HTTP proxy using
+// the CONNECT method.
+func connectDialer(u *url.URL, d *net.Dialer) DialContextFunc {
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		switch network {
+		case "tcp", "tcp4", "tcp6":
+		default:
+			return nil, fmt.Errorf("network %q can't be used through an http proxy", network)
+		}
+
+		conn, err := d.DialContext(ctx, "tcp", proxyAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to proxy: %w", err)
+		}
+		if u.Scheme == "https" {
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+			if err = tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("failed tls handshake with proxy: %w", err)
+			}
+			conn = tlsConn
+		}
+
+		if err = proxyConnect(ctx, conn, u, address); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// proxyConnect asks the HTTP proxy on conn to open a tunnel to address.
+func proxyConnect(ctx context.Context, conn net.Conn, u *url.URL, address string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+		defer conn.SetDeadline(time.Time{}) //nolint:errcheck // Best effort reset.
+	}
+
+	if err := req.Write(conn); err != nil {
+		return fmt.Errorf("failed to send proxy CONNECT request: %w", err)
+	}
+
+	// The response is read byte by byte so that no data sent after it by the
+	// destination is lost in a buffer.
+	resp, err := http.ReadResponse(bufio.NewReaderSize(&byteReader{conn}, 1), req)
+	if err != nil {
+		return fmt.Errorf("failed to read proxy CONNECT response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy CONNECT to %s failed: %s", address, resp.Status)
+	}
+	return nil
+}
+
+// byteReader reads at most one byte at a time.
+type byteReader struct {
+	conn net.Conn
+}
+
+func (r *byteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.conn.Read(p[:1])
+}
+
+// TLSDialer returns a function that connects using dial and performs a TLS
+// handshake with config. The server name is taken from the address if it is
+// not set in config.
+func TLSDialer(dial DialContextFunc, config *tls.Config) DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := config
+		if cfg.ServerName == "" {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			cfg = config.Clone()
+			cfg.ServerName = host
+		}
+
+		tlsConn := tls.Client(conn, cfg)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
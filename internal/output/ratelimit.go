@@ -0,0 +1,148 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Ramp profiles for rate limits.
+const (
+	RampNone   = "none"   // The limits apply from the start.
+	RampLinear = "linear" // The limits increase linearly over the ramp duration.
+	RampStep   = "step"   // The limits increase in equal steps over the ramp duration.
+)
+
+// minRampFraction is the fraction of the limits used at the start of a linear
+// ramp, because a limit of zero would block forever.
+const minRampFraction = 0.01
+
+// RateLimitOptions holds configuration for limiting the throughput of any
+// output.
+type RateLimitOptions struct {
+	EventsPerSecond float64       `config:"events_per_second"` // Maximum events per second. Zero is unlimited.
+	BytesPerSecond  int           `config:"bytes_per_second"`  // Maximum bytes per second. Zero is unlimited.
+	EventBurst      int           `config:"event_burst"`       // Events allowed above the rate at once. Zero is one second worth.
+	ByteBurst       int           `config:"byte_burst"`        // Bytes allowed above the rate at once. Zero is one second worth.
+	Ramp            string        `config:"ramp"`              // Ramp profile (none, linear or step).
+	RampDuration    time.Duration `config:"ramp_duration"`     // Time until the full limits apply.
+	RampSteps       int           `config:"ramp_steps"`        // Number of steps for the step ramp profile.
+}
+
+// rateLimitOutput wraps an Output and delays writes to stay within the
+// configured limits.
+type rateLimitOutput struct {
+	Output
+
+	ctx    context.Context
+	opts   RateLimitOptions
+	events *rate.Limiter
+	bytes  *rate.Limiter
+	start  time.Time // Time of the first write, which starts the ramp.
+
+	// Bursts at the full limits, which are scaled down with them by the ramp.
+	eventBurst int
+	byteBurst  int
+}
+
+func newRateLimitOutput(ctx context.Context, opts RateLimitOptions, out Output) (*rateLimitOutput, error) {
+	switch opts.Ramp {
+	case "", RampNone:
+	case RampLinear, RampStep:
+		if opts.RampDuration <= 0 {
+			return nil, fmt.Errorf("rate limit ramp %q requires a ramp duration", opts.Ramp)
+		}
+		if opts.Ramp == RampStep && opts.RampSteps <= 0 {
+			return nil, fmt.Errorf("rate limit ramp %q requires a number of steps", opts.Ramp)
+		}
+	default:
+		return nil, fmt.Errorf("unknown rate limit ramp %q (use %s, %s or %s)", opts.Ramp, RampNone, RampLinear, RampStep)
+	}
+
+	r := &rateLimitOutput{Output: out, ctx: ctx, opts: opts}
+	if opts.EventsPerSecond > 0 {
+		r.eventBurst = burst(opts.EventsPerSecond, opts.EventBurst)
+		r.events = rate.NewLimiter(rate.Limit(opts.EventsPerSecond), r.eventBurst)
+	}
+	if opts.BytesPerSecond > 0 {
+		r.byteBurst = burst(float64(opts.BytesPerSecond), opts.ByteBurst)
+		r.bytes = rate.NewLimiter(rate.Limit(opts.BytesPerSecond), r.byteBurst)
+	}
+	return r, nil
+}
+
+// burst returns the configured burst, or one second worth of limit if it is
+// not set.
+func burst(limit float64, burst int) int {
+	if burst <= 0 {
+		burst = max(int(math.Ceil(limit)), 1)
+	}
+	return burst
+}
+
+// scaleBurst returns the burst for the fraction f of the full limit.
+func scaleBurst(burst int, f float64) int {
+	return max(int(float64(burst)*f), 1)
+}
+
+// Write waits until b can be written within the limits and writes it.
+func (r *rateLimitOutput) Write(b []byte) (int, error) {
+	now := time.Now()
+	if r.start.IsZero() {
+		r.start = now
+	}
+	f := r.rampFraction(now.Sub(r.start))
+
+	// Setting a smaller burst also discards the tokens above it, so the ramp
+	// doesn't start with the burst of the full limit.
+	if r.events != nil {
+		r.events.SetLimitAt(now, rate.Limit(r.opts.EventsPerSecond*f))
+		r.events.SetBurstAt(now, scaleBurst(r.eventBurst, f))
+		if err := r.events.Wait(r.ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.bytes != nil {
+		r.bytes.SetLimitAt(now, rate.Limit(float64(r.opts.BytesPerSecond)*f))
+		r.bytes.SetBurstAt(now, scaleBurst(r.byteBurst, f))
+		// Events larger than the burst are accounted for in burst sized chunks.
+		for n := len(b); n > 0; n -= r.bytes.Burst() {
+			if err := r.bytes.WaitN(r.ctx, min(n, r.bytes.Burst())); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return r.Output.Write(b)
+}
+
+// StartSource forwards path to the wrapped output.
+func (r *rateLimitOutput) StartSource(path string) error {
+	return StartSource(r.Output, path)
+}
+
+// rampFraction returns the fraction of the limits that applies after elapsed.
+func (r *rateLimitOutput) rampFraction(elapsed time.Duration) float64 {
+	if elapsed >= r.opts.RampDuration {
+		return 1
+	}
+
+	progress := float64(elapsed) / float64(r.opts.RampDuration)
+	switch r.opts.Ramp {
+	case RampLinear:
+		return max(progress, minRampFraction)
+	case RampStep:
+		steps := float64(r.opts.RampSteps)
+		return math.Min((math.Floor(progress*steps)+1)/steps, 1)
+	default:
+		return 1
+	}
+}
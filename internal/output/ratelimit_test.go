@@ -0,0 +1,114 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitEvents(t *testing.T) {
+	server := &flakyServer{}
+	out, err := newRateLimitOutput(context.Background(), RateLimitOptions{EventsPerSecond: 100, EventBurst: 1}, &flakyOutput{server: server})
+	require.NoError(t, err)
+
+	start := time.Now()
+	for range 11 {
+		_, err = out.Write([]byte("x"))
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Len(t, server.events, 11)
+}
+
+func TestRateLimitBytes(t *testing.T) {
+	server := &flakyServer{}
+	out, err := newRateLimitOutput(context.Background(), RateLimitOptions{BytesPerSecond: 10000, ByteBurst: 1000}, &flakyOutput{server: server})
+	require.NoError(t, err)
+
+	// Writes larger than the burst are allowed, but wait for all their bytes.
+	start := time.Now()
+	_, err = out.Write(make([]byte, 3000))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 180*time.Millisecond)
+}
+
+func TestRateLimitSeparateBursts(t *testing.T) {
+	server := &flakyServer{}
+	opts := RateLimitOptions{EventsPerSecond: 1000, EventBurst: 10, BytesPerSecond: 1000, ByteBurst: 100}
+	out, err := newRateLimitOutput(context.Background(), opts, &flakyOutput{server: server})
+	require.NoError(t, err)
+
+	// Ten events of ten bytes fit in both bursts, so they don't wait.
+	start := time.Now()
+	for range 10 {
+		_, err = out.Write(make([]byte, 10))
+		require.NoError(t, err)
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, 10, out.events.Burst())
+	assert.Equal(t, 100, out.bytes.Burst())
+}
+
+func TestRateLimitRampBurst(t *testing.T) {
+	opts := RateLimitOptions{EventsPerSecond: 100, Ramp: RampStep, RampDuration: time.Minute, RampSteps: 4}
+	out, err := newRateLimitOutput(context.Background(), opts, &flakyOutput{server: &flakyServer{}})
+	require.NoError(t, err)
+
+	// The first step allows a quarter of the rate, and of the burst.
+	_, err = out.Write([]byte("x"))
+	require.NoError(t, err)
+	assert.Equal(t, 25, out.events.Burst())
+}
+
+func TestRateLimitCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := newRateLimitOutput(ctx, RateLimitOptions{EventsPerSecond: 1}, &flakyOutput{server: &flakyServer{}})
+	require.NoError(t, err)
+
+	_, err = out.Write([]byte("x"))
+	require.NoError(t, err)
+	cancel()
+	_, err = out.Write([]byte("x"))
+	assert.Error(t, err)
+}
+
+func TestRateLimitRamp(t *testing.T) {
+	testCases := []struct {
+		opts    RateLimitOptions
+		elapsed time.Duration
+		want    float64
+	}{
+		{opts: RateLimitOptions{Ramp: RampNone}, elapsed: 0, want: 1},
+		{opts: RateLimitOptions{Ramp: RampLinear, RampDuration: time.Minute}, elapsed: 0, want: minRampFraction},
+		{opts: RateLimitOptions{Ramp: RampLinear, RampDuration: time.Minute}, elapsed: 15 * time.Second, want: 0.25},
+		{opts: RateLimitOptions{Ramp: RampLinear, RampDuration: time.Minute}, elapsed: time.Hour, want: 1},
+		{opts: RateLimitOptions{Ramp: RampStep, RampDuration: time.Minute, RampSteps: 4}, elapsed: 0, want: 0.25},
+		{opts: RateLimitOptions{Ramp: RampStep, RampDuration: time.Minute, RampSteps: 4}, elapsed: 31 * time.Second, want: 0.75},
+		{opts: RateLimitOptions{Ramp: RampStep, RampDuration: time.Minute, RampSteps: 4}, elapsed: time.Minute, want: 1},
+	}
+
+	for _, tc := range testCases {
+		tc.opts.EventsPerSecond = 1
+		r, err := newRateLimitOutput(context.Background(), tc.opts, nil)
+		require.NoError(t, err)
+		assert.InDelta(t, tc.want, r.rampFraction(tc.elapsed), 1e-9, "%s after %v", tc.opts.Ramp, tc.elapsed)
+	}
+}
+
+func TestRateLimitInvalidRamp(t *testing.T) {
+	for _, opts := range []RateLimitOptions{
+		{Ramp: "exponential", RampDuration: time.Minute},
+		{Ramp: RampLinear},
+		{Ramp: RampStep, RampDuration: time.Minute},
+	} {
+		_, err := newRateLimitOutput(context.Background(), opts, nil)
+		assert.Error(t, err, opts.Ramp)
+	}
+}
@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/elastic/go-concert/timed"
+)
+
+// ReconnectOptions holds configuration for reconnecting outputs after a write
+// fails.
+type ReconnectOptions struct {
+	Enabled        bool          `config:"enabled"`         // Reconnect when a write fails.
+	Resend         bool          `config:"resend"`          // Resend the event whose write failed after reconnecting.
+	InitialBackoff time.Duration `config:"initial_backoff"` // Wait before the first reconnect attempt.
+	MaxBackoff     time.Duration `config:"max_backoff"`     // Maximum wait between reconnect attempts.
+	MaxRetries     int           `config:"max_retries"`     // Reconnect attempts per failed write. Zero retries forever.
+}
+
+const (
+	defaultInitialBackoff = 250 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// reconnectOutput wraps an Output. When a write fails the output is closed,
+// and a new one is created and dialed with exponential backoff and jitter.
+type reconnectOutput struct {
+	Output
+
+	ctx    context.Context
+	opts   *Options
+	logger *zap.SugaredLogger
+	source string // Last path passed to StartSource.
+
+	reconnects int // Successful reconnects.
+	lost       int // Events dropped because their write failed.
+	resent     int // Events resent after a failed write, which may be duplicated.
+}
+
+func newReconnectOutput(ctx context.Context, opts *Options, out Output, logger *zap.SugaredLogger) *reconnectOutput {
+	return &reconnectOutput{
+		Output: out,
+		ctx:    ctx,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Write writes b to the output. If the write fails the output is reconnected.
+// The event is resent if the Resend option is set, otherwise it is counted as
+// lost. Events that a BufferedOutput dropped with it are always counted as
+// lost, because only b can be resent.
+func (r *reconnectOutput) Write(b []byte) (int, error) {
+	n, err := r.Output.Write(b)
+	for err != nil {
+		r.logger.Warnw("Write failed, reconnecting", "error", err)
+		failed := r.Output
+		if err = r.reconnect(); err != nil {
+			return n, err
+		}
+
+		// The failed output has been closed, so its count includes any
+		// events it dropped while closing.
+		dropped := FailedEvents(failed)
+		if !r.opts.ReconnectOptions.Resend {
+			r.lost += dropped
+			return len(b), nil
+		}
+
+		r.lost += dropped - 1
+		r.resent++
+		n, err = r.Output.Write(b)
+	}
+	return n, nil
+}
+
+// StartSource forwards path to the wrapped output.
+func (r *reconnectOutput) StartSource(path string) error {
+	r.source = path
+	return StartSource(r.Output, path)
+}
+
+// Close closes the wrapped output and logs the reconnect statistics.
+func (r *reconnectOutput) Close() error {
+	r.logger.Infow("Reconnect statistics",
+		"reconnects", r.reconnects,
+		"lost", r.lost,
+		"resent", r.resent)
+	return r.Output.Close()
+}
+
+// reconnect dials a clone of the current output and replaces the current one
+// with it. The current output is only closed once the clone is connected.
+func (r *reconnectOutput) reconnect() error {
+	out, err := cloneOutput(r.Output, r.opts, r.logger)
+	if err != nil {
+		return err
+	}
+
+	ropts := r.opts.ReconnectOptions
+	for attempt := 0; ropts.MaxRetries <= 0 || attempt < ropts.MaxRetries; attempt++ {
+		if err := timed.Wait(r.ctx, backoff(ropts, attempt)); err != nil {
+			return errors.Join(err, out.Close())
+		}
+
+		if err = out.DialContext(r.ctx); err != nil {
+			r.logger.Debugw("Reconnect failed", "attempt", attempt+1, "error", err)
+			continue
+		}
+		if r.source != "" {
+			if err = StartSource(out, r.source); err != nil {
+				return errors.Join(err, out.Close())
+			}
+		}
+
+		if err := r.Output.Close(); err != nil {
+			r.logger.Warnw("Failed to close the output whose write failed", "error", err)
+		}
+		r.logger.Info("Reconnected")
+		r.reconnects++
+		r.Output = out
+		return nil
+	}
+	return errors.Join(fmt.Errorf("failed to reconnect after %d attempts: %w", ropts.MaxRetries, err), out.Close())
+}
+
+// backoff returns the wait before the reconnect attempt.
+func backoff(opts ReconnectOptions, attempt int) time.Duration {
+	initial, maxBackoff := opts.InitialBackoff, opts.MaxBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	return Backoff(initial, maxBackoff, attempt)
+}
+
+// Backoff returns the wait before a retry attempt, counted from zero. It
+// doubles with each attempt up to maxBackoff, and jitter picks a random wait
+// between half and all of it.
+func Backoff(initial, maxBackoff time.Duration, attempt int) time.Duration {
+	d := maxBackoff
+	if attempt < 32 {
+		d = min(initial<<attempt, maxBackoff)
+	}
+	if d <= 0 {
+		d = maxBackoff
+	}
+	return d/2 + rand.N(d/2+1) //nolint:gosec // Jitter does not need a secure random source.
+}
@@ -0,0 +1,211 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// flakyServer records the events written by flakyOutputs and fails the
+// configured number of writes and dials.
+type flakyServer struct {
+	events      []string
+	failWrites  int
+	failDials   int
+	dials       int
+	closes      int
+	sourcePaths []string
+}
+
+type flakyOutput struct {
+	server *flakyServer
+}
+
+func (o *flakyOutput) DialContext(context.Context) error {
+	o.server.dials++
+	if o.server.failDials > 0 {
+		o.server.failDials--
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (o *flakyOutput) Write(b []byte) (int, error) {
+	if o.server.failWrites > 0 {
+		o.server.failWrites--
+		return 0, errors.New("broken pipe")
+	}
+	o.server.events = append(o.server.events, string(b))
+	return len(b), nil
+}
+
+func (o *flakyOutput) WriteBatch(batch [][]byte) error {
+	if o.server.failWrites > 0 {
+		o.server.failWrites--
+		return errors.New("broken pipe")
+	}
+	for _, b := range batch {
+		o.server.events = append(o.server.events, string(b))
+	}
+	return nil
+}
+
+func (o *flakyOutput) StartSource(path string) error {
+	o.server.sourcePaths = append(o.server.sourcePaths, path)
+	return nil
+}
+
+func (o *flakyOutput) Close() error {
+	o.server.closes++
+	return nil
+}
+
+func newFlakyOutput(t *testing.T, server *flakyServer, ropts ReconnectOptions) Output {
+	t.Helper()
+	return newFlakyBatchOutput(t, server, ropts, BatchOptions{})
+}
+
+func newFlakyBatchOutput(t *testing.T, server *flakyServer, ropts ReconnectOptions, bopts BatchOptions) Output {
+	t.Helper()
+
+	Register("flaky-test", func(*Options) (Output, error) {
+		return &flakyOutput{server: server}, nil
+	})
+	t.Cleanup(func() { delete(registry, "flaky-test") })
+
+	ropts.Enabled = true
+	ropts.InitialBackoff = time.Millisecond
+	ropts.MaxBackoff = time.Millisecond
+	opts := &Options{Protocol: "flaky-test", Retries: 1, ReconnectOptions: ropts, BatchOptions: bopts}
+
+	out, err := Initialize(context.Background(), opts, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	return out
+}
+
+func TestReconnect(t *testing.T) {
+	server := &flakyServer{}
+	out := newFlakyOutput(t, server, ReconnectOptions{})
+	require.NoError(t, StartSource(out, "a.log"))
+
+	_, err := out.Write([]byte("1"))
+	require.NoError(t, err)
+
+	server.failWrites, server.failDials = 1, 2
+	n, err := out.Write([]byte("2"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = out.Write([]byte("3"))
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	r := out.(*reconnectOutput)
+	assert.Equal(t, []string{"1", "3"}, server.events)
+	assert.Equal(t, 4, server.dials)
+	assert.Equal(t, 1, r.reconnects)
+	assert.Equal(t, 1, r.lost)
+	assert.Equal(t, []string{"a.log", "a.log"}, server.sourcePaths)
+}
+
+func TestReconnectResend(t *testing.T) {
+	server := &flakyServer{}
+	out := newFlakyOutput(t, server, ReconnectOptions{Resend: true})
+
+	server.failWrites = 2
+	_, err := out.Write([]byte("1"))
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	r := out.(*reconnectOutput)
+	assert.Equal(t, []string{"1"}, server.events)
+	assert.Equal(t, 2, r.reconnects)
+	assert.Equal(t, 2, r.resent)
+	assert.Equal(t, 0, r.lost)
+}
+
+func TestReconnectBatch(t *testing.T) {
+	testCases := []struct {
+		name   string
+		resend bool
+		events []string
+		lost   int
+		resent int
+	}{
+		{name: "drop", events: nil, lost: 2},
+		{name: "resend", resend: true, events: []string{"2"}, lost: 1, resent: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &flakyServer{}
+			out := newFlakyBatchOutput(t, server, ReconnectOptions{Resend: tc.resend}, BatchOptions{Count: 2})
+
+			// The second write flushes the batch, so both events are lost
+			// when it fails.
+			_, err := out.Write([]byte("1"))
+			require.NoError(t, err)
+			server.failWrites = 1
+			_, err = out.Write([]byte("2"))
+			require.NoError(t, err)
+			require.NoError(t, out.Close())
+
+			r := out.(*reconnectOutput)
+			assert.Equal(t, tc.events, server.events)
+			assert.Equal(t, 1, r.reconnects)
+			assert.Equal(t, tc.lost, r.lost)
+			assert.Equal(t, tc.resent, r.resent)
+		})
+	}
+}
+
+func TestReconnectMaxRetries(t *testing.T) {
+	server := &flakyServer{}
+	out := newFlakyOutput(t, server, ReconnectOptions{MaxRetries: 2})
+
+	server.failWrites, server.failDials = 1, 10
+	_, err := out.Write([]byte("1"))
+	assert.ErrorContains(t, err, "failed to reconnect after 2 attempts")
+	assert.Equal(t, 1, server.closes, "the replacement output is closed")
+}
+
+func TestReconnectCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &flakyServer{}
+	Register("flaky-test", func(*Options) (Output, error) {
+		return &flakyOutput{server: server}, nil
+	})
+	t.Cleanup(func() { delete(registry, "flaky-test") })
+
+	opts := &Options{Protocol: "flaky-test", Retries: 1, ReconnectOptions: ReconnectOptions{Enabled: true, InitialBackoff: time.Minute}}
+	out, err := Initialize(ctx, opts, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	server.failWrites = 1
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err = out.Write([]byte("1"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, server.closes, "the replacement output is closed")
+}
+
+func TestBackoff(t *testing.T) {
+	opts := ReconnectOptions{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		for range 10 {
+			d := backoff(opts, attempt)
+			assert.GreaterOrEqual(t, d, want/2)
+			assert.LessOrEqual(t, d, want)
+		}
+	}
+	assert.LessOrEqual(t, backoff(opts, 100), time.Second)
+}
@@ -0,0 +1,123 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions holds the TLS client configuration shared by all outputs that
+// support TLS.
+type TLSOptions struct {
+	ClientCert   string   `config:"client_cert"`   // Path to a PEM encoded client certificate.
+	ClientKey    string   `config:"client_key"`    // Path to the PEM encoded key of the client certificate.
+	CA           string   `config:"ca"`            // Path to a PEM encoded CA bundle used to verify the server.
+	ServerName   string   `config:"server_name"`   // Server name used for SNI and certificate verification.
+	MinVersion   string   `config:"min_version"`   // Minimum TLS version (1.0, 1.1, 1.2 or 1.3).
+	MaxVersion   string   `config:"max_version"`   // Maximum TLS version (1.0, 1.1, 1.2 or 1.3).
+	CipherSuites []string `config:"cipher_suites"` // Cipher suite names (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256).
+	ALPN         []string `config:"alpn"`          // Application protocols to negotiate (e.g. h2, http/1.1).
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig returns the TLS client configuration built from the TLS options
+// and InsecureTLS.
+func (o *Options) TLSConfig() (*tls.Config, error) {
+	opts := o.TLSOptions
+
+	config := &tls.Config{
+		InsecureSkipVerify: o.InsecureTLS, //nolint:gosec // User controlled option.
+		ServerName:         opts.ServerName,
+		NextProtos:         opts.ALPN,
+	}
+
+	switch {
+	case opts.ClientCert != "" && opts.ClientKey != "":
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	case opts.ClientCert != "" || opts.ClientKey != "":
+		return nil, errors.New("tls client certificate and key must be used together")
+	}
+
+	if opts.CA != "" {
+		pem, err := os.ReadFile(opts.CA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls ca %q", opts.CA)
+		}
+		config.RootCAs = pool
+	}
+
+	var err error
+	if config.MinVersion, err = parseTLSVersion(opts.MinVersion); err != nil {
+		return nil, err
+	}
+	if config.MaxVersion, err = parseTLSVersion(opts.MaxVersion); err != nil {
+		return nil, err
+	}
+	if config.MinVersion != 0 && config.MaxVersion != 0 && config.MinVersion > config.MaxVersion {
+		return nil, fmt.Errorf("tls min version %s is greater than max version %s", opts.MinVersion, opts.MaxVersion)
+	}
+
+	if config.CipherSuites, err = parseCipherSuites(opts.CipherSuites); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// parseTLSVersion returns the TLS version for s. An empty string returns zero,
+// which means the crypto/tls default is used.
+func parseTLSVersion(s string) (uint16, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, found := tlsVersions[strings.TrimPrefix(strings.ToLower(s), "tls")]
+	if !found {
+		return 0, fmt.Errorf("unknown tls version %q (use 1.0, 1.1, 1.2 or 1.3)", s)
+	}
+	return v, nil
+}
+
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	suites := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		suites[s.Name] = s.ID
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		suites[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, found := suites[strings.ToUpper(name)]
+		if !found {
+			return nil, fmt.Errorf("unknown tls cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,150 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConfig(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts TLSOptions
+		fail bool
+	}{
+		{name: "defaults"},
+		{name: "versions", opts: TLSOptions{MinVersion: "1.2", MaxVersion: "TLS1.3"}},
+		{name: "unknown version", opts: TLSOptions{MinVersion: "2.0"}, fail: true},
+		{name: "min greater than max", opts: TLSOptions{MinVersion: "1.3", MaxVersion: "1.2"}, fail: true},
+		{name: "cipher suites", opts: TLSOptions{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}},
+		{name: "unknown cipher suite", opts: TLSOptions{CipherSuites: []string{"TLS_FOO"}}, fail: true},
+		{name: "cert without key", opts: TLSOptions{ClientCert: "cert.pem"}, fail: true},
+		{name: "missing ca", opts: TLSOptions{CA: "missing.pem"}, fail: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &Options{TLSOptions: tc.opts}
+			config, err := opts.TLSConfig()
+			if tc.fail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, config)
+		})
+	}
+}
+
+func TestTLSConfigMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newCertificate(t, nil, nil, "ca")
+	server, serverKey := newCertificate(t, ca, caKey, "server")
+	client, clientKey := newCertificate(t, ca, caKey, "client")
+
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", ca.Raw)
+	clientCertFile := writePEM(t, dir, "client.pem", "CERTIFICATE", client.Raw)
+	clientKeyFile := writePEM(t, dir, "client-key.pem", "PRIVATE KEY", marshalKey(t, clientKey))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	l, err := tls.Listen("tcp", "localhost:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey}},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		NextProtos:   []string{"stream"},
+		MinVersion:   tls.VersionTLS12,
+	})
+	require.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	opts := &Options{TLSOptions: TLSOptions{
+		ClientCert: clientCertFile,
+		ClientKey:  clientKeyFile,
+		CA:         caFile,
+		ServerName: "server",
+		MinVersion: "1.3",
+		ALPN:       []string{"stream"},
+	}}
+	config, err := opts.TLSConfig()
+	require.NoError(t, err)
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), config)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	assert.Equal(t, uint16(tls.VersionTLS13), state.Version)
+	assert.Equal(t, "stream", state.NegotiatedProtocol)
+}
+
+// newCertificate returns a certificate for name signed by parent. The
+// certificate is a self-signed CA if parent is nil.
+func newCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func marshalKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return der
+}
+
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+	return path
+}
@@ -6,6 +6,7 @@ package output
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"go.uber.org/zap"
@@ -18,23 +19,63 @@ import (
 // connected Output or an error if the connection could not be established within
 // the allowed retries or if the provided context is canceled. The logger is used
 // for informational and debug messages during initialization and connection
-// attempts.
+// attempts. If reconnecting is enabled, the returned Output reconnects when a
+// write fails. If more than one worker is configured, the returned Output
+// distributes writes across that many outputs, each with its own connection.
+// If rate limits are set, writes to the returned Output are delayed to stay
+// within them. If fan-out outputs are configured, each of them is initialized
+// with its own options, and the returned Output writes to all of them.
 func Initialize(ctx context.Context, opts *Options, logger *zap.SugaredLogger) (Output, error) {
-	o, err := New(opts)
+	if len(opts.Fanout) > 0 {
+		return newFanout(ctx, opts, logger)
+	}
+
+	var (
+		o   Output
+		err error
+	)
+	if opts.WorkerOptions.Workers > 1 {
+		o, err = newWorkerPool(ctx, opts, logger)
+	} else {
+		o, err = connect(ctx, opts, logger)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.RateLimitOptions.EventsPerSecond > 0 || opts.RateLimitOptions.BytesPerSecond > 0 {
+		rl, err := newRateLimitOutput(ctx, opts.RateLimitOptions, o)
+		if err != nil {
+			return nil, errors.Join(err, o.Close())
+		}
+		o = rl
+	}
+
+	return o, nil
+}
+
+// connect creates a new Output and dials it with retries.
+func connect(ctx context.Context, opts *Options, logger *zap.SugaredLogger) (Output, error) {
+	o, err := newOutput(opts, logger)
+	if err != nil {
+		return nil, err
+	}
+	return dial(ctx, opts, o, logger)
+}
+
+// dial dials o with retries. o is closed if it cannot be dialed.
+func dial(ctx context.Context, opts *Options, o Output, logger *zap.SugaredLogger) (Output, error) {
+	// Retries is the number of dial attempts, and there is always at least one.
 	var dialErr error
-	for i := 0; i < opts.Retries; i++ {
+	for i := 0; i < max(opts.Retries, 1); i++ {
 		if ctx.Err() != nil {
-			return nil, ctx.Err()
+			return nil, errors.Join(ctx.Err(), o.Close())
 		}
 
 		logger.Debug("Connecting...")
 		if dialErr = o.DialContext(ctx); dialErr != nil {
-			if err = timed.Wait(ctx, time.Second); err != nil {
-				return nil, err
+			if err := timed.Wait(ctx, time.Second); err != nil {
+				return nil, errors.Join(err, o.Close())
 			}
 			continue
 		}
@@ -42,9 +83,56 @@ func Initialize(ctx context.Context, opts *Options, logger *zap.SugaredLogger) (
 		break
 	}
 	if dialErr != nil {
-		return nil, dialErr
+		return nil, errors.Join(dialErr, o.Close())
 	}
 	logger.Info("Connected")
 
+	if opts.ReconnectOptions.Enabled {
+		o = newReconnectOutput(ctx, opts, o, logger)
+	}
+
+	return o, nil
+}
+
+// newOutput creates a new Output. If batching is enabled and the output
+// implements BatchOutput, events are batched.
+func newOutput(opts *Options, logger *zap.SugaredLogger) (Output, error) {
+	o, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.BatchOptions.Enabled() {
+		bo, ok := o.(BatchOutput)
+		if !ok {
+			logger.Warnw("Output does not support batching, events are written one at a time", "protocol", opts.Protocol)
+			return o, nil
+		}
+		return newBatcher(bo, opts.BatchOptions), nil
+	}
+
+	return o, nil
+}
+
+// cloneOutput creates a new Output like out, which was created by newOutput
+// for opts. Outputs that implement Cloner share their state with the clone,
+// others are created anew.
+func cloneOutput(out Output, opts *Options, logger *zap.SugaredLogger) (Output, error) {
+	b, batched := out.(*batcher)
+	if batched {
+		out = b.BatchOutput
+	}
+	c, ok := out.(Cloner)
+	if !ok {
+		return newOutput(opts, logger)
+	}
+
+	o, err := c.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if bo, ok := o.(BatchOutput); ok && batched {
+		return newBatcher(bo, b.opts), nil
+	}
 	return o, nil
 }
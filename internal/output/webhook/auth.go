@@ -0,0 +1,189 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+const (
+	defaultHMACHeader = "X-Hub-Signature-256"
+	defaultAWSService = "execute-api"
+)
+
+// authenticator adds the configured authentication to webhook requests.
+type authenticator struct {
+	username, password string
+	bearerToken        string
+
+	hmacSecret   []byte
+	hmacHeader   string
+	hmacPrefix   string
+	hmacEncoding func([]byte) string
+
+	signer     *v4.Signer
+	awsCreds   aws.Credentials
+	awsService string
+	awsRegion  string
+}
+
+func newAuthenticator(opts output.WebhookOptions) (*authenticator, error) {
+	a := &authenticator{
+		username:    opts.Username,
+		password:    opts.Password,
+		bearerToken: opts.BearerToken,
+	}
+
+	if opts.BearerTokenFile != "" {
+		if a.bearerToken != "" {
+			return nil, errors.New("webhook bearer token and bearer token file can't both be set")
+		}
+		b, err := os.ReadFile(opts.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bearer token file: %w", err)
+		}
+		a.bearerToken = strings.TrimSpace(string(b))
+		if a.bearerToken == "" {
+			return nil, fmt.Errorf("bearer token file %s is empty", opts.BearerTokenFile)
+		}
+	}
+
+	// All of these schemes use the Authorization header.
+	var schemes []string
+	if a.username != "" && a.password != "" {
+		schemes = append(schemes, "basic")
+	}
+	if a.bearerToken != "" {
+		schemes = append(schemes, "bearer")
+	}
+	if opts.OAuth2TokenURL != "" {
+		schemes = append(schemes, "oauth2")
+	}
+	if opts.AWSRegion != "" {
+		schemes = append(schemes, "aws sigv4")
+	}
+	if len(schemes) > 1 {
+		return nil, fmt.Errorf("only one webhook authentication scheme can be used, got %s", strings.Join(schemes, ", "))
+	}
+
+	if opts.HMACSecret != "" {
+		a.hmacSecret = []byte(opts.HMACSecret)
+		a.hmacHeader = opts.HMACHeader
+		if a.hmacHeader == "" {
+			a.hmacHeader = defaultHMACHeader
+		}
+		a.hmacPrefix = opts.HMACPrefix
+		switch opts.HMACEncoding {
+		case "", "hex":
+			a.hmacEncoding = hex.EncodeToString
+		case "base64":
+			a.hmacEncoding = base64.StdEncoding.EncodeToString
+		default:
+			return nil, fmt.Errorf("unknown hmac encoding %q (use hex or base64)", opts.HMACEncoding)
+		}
+	}
+
+	if opts.AWSRegion != "" {
+		a.signer = v4.NewSigner()
+		a.awsRegion = opts.AWSRegion
+		a.awsService = opts.AWSService
+		if a.awsService == "" {
+			a.awsService = defaultAWSService
+		}
+		a.awsCreds = aws.Credentials{
+			AccessKeyID:     valueOrEnv(opts.AWSAccessKeyID, "AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: valueOrEnv(opts.AWSSecretAccessKey, "AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    valueOrEnv(opts.AWSSessionToken, "AWS_SESSION_TOKEN"),
+		}
+		if a.awsCreds.AccessKeyID == "" || a.awsCreds.SecretAccessKey == "" {
+			return nil, errors.New("aws sigv4 signing requires an access key id and secret access key")
+		}
+	}
+
+	return a, nil
+}
+
+// authorize adds authentication to req, which has the given body. It must be
+// called after all other headers are set, because AWS SigV4 signs them.
+func (a *authenticator) authorize(req *http.Request, body []byte) error {
+	if a.username != "" && a.password != "" {
+		req.SetBasicAuth(a.username, a.password)
+	}
+	if a.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+a.bearerToken)
+	}
+
+	if a.hmacSecret != nil {
+		mac := hmac.New(sha256.New, a.hmacSecret)
+		mac.Write(body)
+		req.Header.Set(a.hmacHeader, a.hmacPrefix+a.hmacEncoding(mac.Sum(nil)))
+	}
+
+	if a.signer != nil {
+		hash := sha256.Sum256(body)
+		err := a.signer.SignHTTP(req.Context(), a.awsCreds, req, hex.EncodeToString(hash[:]), a.awsService, a.awsRegion, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+	return nil
+}
+
+// oauth2Transport returns a transport that adds an OAuth2 client credentials
+// token to requests sent with base. Tokens are cached and refreshed before they
+// expire. The token endpoint is called using base.
+func oauth2Transport(opts output.WebhookOptions, base http.RoundTripper, timeout time.Duration) (http.RoundTripper, error) {
+	if _, err := url.Parse(opts.OAuth2TokenURL); err != nil {
+		return nil, fmt.Errorf("invalid oauth2 token url: %w", err)
+	}
+
+	params, err := output.SplitKeyValues(opts.OAuth2EndpointParams)
+	if err != nil {
+		return nil, fmt.Errorf("invalid oauth2 endpoint param: %w", err)
+	}
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+
+	config := &clientcredentials.Config{
+		ClientID:       opts.OAuth2ClientID,
+		ClientSecret:   opts.OAuth2ClientSecret,
+		TokenURL:       opts.OAuth2TokenURL,
+		Scopes:         opts.OAuth2Scopes,
+		EndpointParams: values,
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Transport: base,
+		Timeout:   timeout,
+	})
+	return &oauth2.Transport{Source: config.TokenSource(ctx), Base: base}, nil
+}
+
+func valueOrEnv(value, env string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
@@ -0,0 +1,175 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+const body = `{"message":"hello"}`
+
+// postWith sends body to a test server with opts and returns the received
+// request and its body.
+func postWith(t *testing.T, opts output.WebhookOptions) (*http.Request, []byte) {
+	t.Helper()
+
+	var req *http.Request
+	var reqBody []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var err error
+		reqBody, err = io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		req = r
+	}))
+	defer ts.Close()
+
+	opts.Timeout = time.Second
+	opts.Probe = "false"
+	out, err := New(&output.Options{Addr: ts.URL + "/logs", WebhookOptions: opts})
+	require.NoError(t, err)
+	defer out.Close()
+
+	_, err = out.Write([]byte(body))
+	require.NoError(t, err)
+	require.NotNil(t, req)
+	return req, reqBody
+}
+
+func TestWebhookBearerToken(t *testing.T) {
+	req, _ := postWith(t, output.WebhookOptions{BearerToken: "secret-token"})
+	assert.Equal(t, "Bearer secret-token", req.Header.Get("Authorization"))
+
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("file-token\n"), 0o600))
+	req, _ = postWith(t, output.WebhookOptions{BearerTokenFile: path})
+	assert.Equal(t, "Bearer file-token", req.Header.Get("Authorization"))
+}
+
+func TestWebhookOAuth2(t *testing.T) {
+	var tokenRequests atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		assert.Equal(t, "read write", r.Form.Get("scope"))
+		assert.Equal(t, "api", r.Form.Get("audience"))
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "client", user)
+		assert.Equal(t, "client-secret", pass)
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"oauth-token","token_type":"Bearer","expires_in":3600}`) //nolint:errcheck // Test server.
+	}))
+	defer tokenServer.Close()
+
+	var authorized atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer oauth-token" {
+			authorized.Add(1)
+		}
+	}))
+	defer ts.Close()
+
+	out, err := New(&output.Options{
+		Addr: ts.URL,
+		WebhookOptions: output.WebhookOptions{
+			Timeout:              time.Second,
+			OAuth2TokenURL:       tokenServer.URL,
+			OAuth2ClientID:       "client",
+			OAuth2ClientSecret:   "client-secret",
+			OAuth2Scopes:         []string{"read", "write"},
+			OAuth2EndpointParams: []string{"audience=api"},
+		},
+	})
+	require.NoError(t, err)
+	defer out.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err = out.Write([]byte(body))
+		require.NoError(t, err)
+	}
+
+	assert.EqualValues(t, 3, authorized.Load())
+	assert.EqualValues(t, 1, tokenRequests.Load(), "expected the token to be cached")
+}
+
+func TestWebhookHMAC(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("hmac-secret"))
+	mac.Write([]byte(body))
+	sum := mac.Sum(nil)
+
+	req, _ := postWith(t, output.WebhookOptions{HMACSecret: "hmac-secret", HMACPrefix: "sha256="})
+	assert.Equal(t, "sha256="+hex.EncodeToString(sum), req.Header.Get(defaultHMACHeader))
+
+	req, _ = postWith(t, output.WebhookOptions{HMACSecret: "hmac-secret", HMACHeader: "X-Signature", HMACEncoding: "base64"})
+	assert.Equal(t, base64.StdEncoding.EncodeToString(sum), req.Header.Get("X-Signature"))
+}
+
+func TestWebhookAWSSigV4(t *testing.T) {
+	creds := aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"}
+	req, reqBody := postWith(t, output.WebhookOptions{
+		ContentType:        contentType,
+		AWSRegion:          "us-east-1",
+		AWSAccessKeyID:     creds.AccessKeyID,
+		AWSSecretAccessKey: creds.SecretAccessKey,
+		AWSSessionToken:    creds.SessionToken,
+	})
+
+	auth := req.Header.Get("Authorization")
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"), auth)
+	assert.Contains(t, auth, "/us-east-1/execute-api/aws4_request")
+	assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+
+	// Verify the signature by signing the received request again.
+	signingTime, err := time.Parse("20060102T150405Z", req.Header.Get("X-Amz-Date"))
+	require.NoError(t, err)
+	verify := req.Clone(req.Context())
+	verify.Header.Del("Authorization")
+	verify.Header.Del("Accept-Encoding") // Added by the transport after signing.
+	verify.URL.Host = req.Host
+	hash := sha256.Sum256(reqBody)
+	err = v4.NewSigner().SignHTTP(req.Context(), creds, verify, hex.EncodeToString(hash[:]), "execute-api", "us-east-1", signingTime)
+	require.NoError(t, err)
+	assert.Equal(t, verify.Header.Get("Authorization"), auth)
+}
+
+func TestWebhookAuthConflict(t *testing.T) {
+	_, err := New(&output.Options{
+		Addr: "http://localhost",
+		WebhookOptions: output.WebhookOptions{
+			Username:    username,
+			Password:    password,
+			BearerToken: "token",
+		},
+	})
+	assert.ErrorContains(t, err, "only one webhook authentication scheme")
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	_, err = New(&output.Options{
+		Addr:           "http://localhost",
+		WebhookOptions: output.WebhookOptions{AWSRegion: "us-east-1"},
+	})
+	assert.ErrorContains(t, err, "access key id")
+}
@@ -0,0 +1,131 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package webhook
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Batch body formats.
+const (
+	BatchFormatNDJSON    = "ndjson"     // Events separated by newlines.
+	BatchFormatJSONArray = "json-array" // Events as the elements of a JSON array.
+)
+
+// joinBatch returns the request body of a batch of events in the given format.
+func joinBatch(batch [][]byte, format string) []byte {
+	if format == BatchFormatJSONArray {
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i, b := range batch {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(b)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes()
+	}
+	return bytes.Join(batch, []byte("\n"))
+}
+
+// encoder compresses request bodies.
+type encoder func(b []byte) ([]byte, error)
+
+// newEncoder returns the encoder for the compression method and the value of
+// the Content-Encoding header. The encoder is nil if there is no compression.
+func newEncoder(compression string) (encoder, string, error) {
+	switch compression {
+	case "", "none":
+		return nil, "", nil
+	case "gzip":
+		return streamEncoder(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }), "gzip", nil
+	case "deflate":
+		// The deflate content coding is the zlib format.
+		return streamEncoder(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }), "deflate", nil
+	case "zstd":
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+		return func(b []byte) ([]byte, error) { return enc.EncodeAll(b, nil), nil }, "zstd", nil
+	default:
+		return nil, "", fmt.Errorf("unknown compression %q (use none, gzip, deflate or zstd)", compression)
+	}
+}
+
+func streamEncoder(newWriter func(io.Writer) io.WriteCloser) encoder {
+	return func(b []byte) ([]byte, error) {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// parseAcceptStatus returns a function that reports whether a response status
+// code is accepted. The codes are exact codes (e.g. 202) or classes (e.g. 2xx).
+// An empty list accepts 2xx.
+func parseAcceptStatus(codes []string) (func(code int) bool, error) {
+	if len(codes) == 0 {
+		codes = []string{"2xx"}
+	}
+
+	var classes [10]bool
+	exact := map[int]bool{}
+	for _, c := range codes {
+		c = strings.TrimSpace(c)
+		if len(c) == 3 && strings.EqualFold(c[1:], "xx") && c[0] >= '1' && c[0] <= '5' {
+			classes[c[0]-'0'] = true
+			continue
+		}
+		code, err := strconv.Atoi(c)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid accepted status code %q (e.g. 2xx or 202)", c)
+		}
+		exact[code] = true
+	}
+
+	return func(code int) bool {
+		return exact[code] || (code >= 100 && code <= 599 && classes[code/100])
+	}, nil
+}
+
+// isRetryable reports whether a request answered with the status code should
+// be retried.
+func isRetryable(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// retryAfter returns the wait requested by a Retry-After header, which is
+// either a number of seconds or an HTTP date.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
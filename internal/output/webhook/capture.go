@@ -0,0 +1,290 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+// responseRecord is a response written to the response file.
+type responseRecord struct {
+	Timestamp  time.Time   `json:"@timestamp"`
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Attempt    int         `json:"attempt"`
+	Status     int         `json:"status"`
+	Headers    http.Header `json:"headers"`
+	Body       string      `json:"body"`
+	LatencyMS  float64     `json:"latency_ms"`
+	Violations []string    `json:"violations,omitempty"`
+}
+
+// capture records responses and checks them against the assertions. The
+// outputs cloned by workers and reconnects share it, so that responses are
+// checked and counted once for the whole run.
+type capture struct {
+	mu   sync.Mutex // Serializes records from concurrent workers.
+	refs int        // Outputs using the capture.
+	file *os.File   // Nil if responses are not recorded.
+
+	status    func(code int) bool
+	bodyRegex *regexp.Regexp
+	jsonPaths []jsonPathAssertion
+
+	responses  int    // Responses checked against the assertions.
+	violations int    // Responses that failed an assertion.
+	first      string // First violation.
+}
+
+type jsonPathAssertion struct {
+	path     string
+	segments []jsonPathSegment
+	value    string
+	exact    bool // Compare the value, otherwise the path only needs to exist.
+}
+
+// newCapture returns a capture used by one output. Each output that shares it
+// must acquire it, and close it when done.
+func newCapture(opts output.WebhookOptions) (*capture, error) {
+	c := &capture{refs: 1}
+
+	if len(opts.ExpectStatus) > 0 {
+		status, err := parseAcceptStatus(opts.ExpectStatus)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expected status: %w", err)
+		}
+		c.status = status
+	}
+	if opts.ExpectBodyRegex != "" {
+		re, err := regexp.Compile(opts.ExpectBodyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expected body regex: %w", err)
+		}
+		c.bodyRegex = re
+	}
+	for _, a := range opts.ExpectJSONPath {
+		path, value, exact := strings.Cut(a, "=")
+		segments, err := parseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		c.jsonPaths = append(c.jsonPaths, jsonPathAssertion{path: path, segments: segments, value: value, exact: exact})
+	}
+
+	if opts.ResponseFile != "" {
+		// Responses are appended so that outputs with different options,
+		// such as fan-out outputs, can share the file.
+		f, err := os.OpenFile(opts.ResponseFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open response file: %w", err)
+		}
+		c.file = f
+	}
+	return c, nil
+}
+
+// acquire adds an output that uses the capture.
+func (c *capture) acquire() *capture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refs++
+	return c
+}
+
+func (c *capture) hasAssertions() bool {
+	return c.status != nil || c.bodyRegex != nil || len(c.jsonPaths) > 0
+}
+
+// check returns the assertions that resp and its body violate.
+func (c *capture) check(resp *http.Response, body []byte) []string {
+	var violations []string
+	if c.status != nil && !c.status(resp.StatusCode) {
+		violations = append(violations, fmt.Sprintf("unexpected status %d", resp.StatusCode))
+	}
+	if c.bodyRegex != nil && !c.bodyRegex.Match(body) {
+		violations = append(violations, fmt.Sprintf("body does not match %q", c.bodyRegex))
+	}
+	if len(c.jsonPaths) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			return append(violations, "body is not valid JSON")
+		}
+		for _, a := range c.jsonPaths {
+			value, found := evalJSONPath(doc, a.segments)
+			switch {
+			case !found:
+				violations = append(violations, fmt.Sprintf("%s not found", a.path))
+			case a.exact && value != a.value:
+				violations = append(violations, fmt.Sprintf("%s is %q, expected %q", a.path, value, a.value))
+			}
+		}
+	}
+	return violations
+}
+
+// record checks a response if final is set and writes it to the response
+// file.
+func (c *capture) record(req *http.Request, attempt int, resp *http.Response, body []byte, latency time.Duration, final bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var violations []string
+	if final && c.hasAssertions() {
+		c.responses++
+		if violations = c.check(resp, body); len(violations) > 0 {
+			c.violations++
+			if c.first == "" {
+				c.first = strings.Join(violations, ", ")
+			}
+		}
+	}
+
+	if c.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(responseRecord{
+		Timestamp:  time.Now().UTC(),
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Attempt:    attempt + 1,
+		Status:     resp.StatusCode,
+		Headers:    resp.Header,
+		Body:       string(body),
+		LatencyMS:  float64(latency) / float64(time.Millisecond),
+		Violations: violations,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = c.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write response file: %w", err)
+	}
+	return nil
+}
+
+// Close releases the capture. When the last output using it is closed, the
+// response file is closed and an error is returned if any response failed an
+// assertion.
+func (c *capture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refs--; c.refs > 0 {
+		return nil
+	}
+
+	var err error
+	if c.file != nil {
+		err = c.file.Close()
+	}
+	if c.violations > 0 {
+		err = errors.Join(err, fmt.Errorf("%d of %d webhook responses failed assertions, first: %s", c.violations, c.responses, c.first))
+	}
+	return err
+}
+
+// jsonPathSegment is a key or an array index of a JSONPath.
+type jsonPathSegment struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// parseJSONPath parses the subset of JSONPath that selects a single value
+// using keys and array indexes, such as $.items[0].id or $['a key'].
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(path), "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid jsonpath %q: must start with $", path)
+	}
+
+	var segments []jsonPathSegment
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid jsonpath %q: empty key", path)
+			}
+			segments = append(segments, jsonPathSegment{key: rest[:end], isKey: true})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid jsonpath %q: missing ]", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, jsonPathSegment{key: inner[1 : len(inner)-1], isKey: true})
+				continue
+			}
+			i, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jsonpath %q: bad index %q", path, inner)
+			}
+			segments = append(segments, jsonPathSegment{index: i})
+		default:
+			return nil, fmt.Errorf("invalid jsonpath %q: unexpected %q", path, rest[0])
+		}
+	}
+	return segments, nil
+}
+
+// evalJSONPath returns the value at the parsed path segments in doc. Strings
+// are returned as-is and any other value is returned in its JSON encoding.
+func evalJSONPath(doc interface{}, segments []jsonPathSegment) (string, bool) {
+	v := doc
+	for _, s := range segments {
+		if s.isKey {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return "", false
+			}
+			if v, ok = m[s.key]; !ok {
+				return "", false
+			}
+			continue
+		}
+		a, ok := v.([]interface{})
+		if !ok {
+			return "", false
+		}
+		i := s.index
+		if i < 0 {
+			i += len(a)
+		}
+		if i < 0 || i >= len(a) {
+			return "", false
+		}
+		v = a[i]
+	}
+
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(raw), true
+}
@@ -0,0 +1,170 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+func TestWebhookResponseFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Echo", string(data))
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, `{"status":"ok"}`) //nolint:errcheck // Test server.
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "responses.jsonl")
+	out, err := New(&output.Options{
+		Addr: ts.URL,
+		WebhookOptions: output.WebhookOptions{
+			Timeout:      time.Second,
+			ResponseFile: path,
+		},
+	})
+	require.NoError(t, err)
+
+	for _, event := range []string{"a", "b"} {
+		_, err = out.Write([]byte(event))
+		require.NoError(t, err)
+	}
+	require.NoError(t, out.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []responseRecord
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var r responseRecord
+		require.NoError(t, json.Unmarshal(s.Bytes(), &r))
+		records = append(records, r)
+	}
+	require.NoError(t, s.Err())
+
+	require.Len(t, records, 2)
+	for i, event := range []string{"a", "b"} {
+		r := records[i]
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, ts.URL, r.URL)
+		assert.Equal(t, 1, r.Attempt)
+		assert.Equal(t, http.StatusAccepted, r.Status)
+		assert.Equal(t, event, r.Headers.Get("X-Echo"))
+		assert.Equal(t, `{"status":"ok"}`, r.Body)
+		assert.Greater(t, r.LatencyMS, 0.0)
+		assert.Empty(t, r.Violations)
+	}
+}
+
+func TestWebhookAssertions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, `{"status":"ok","items":[{"id":1},{"id":2}]}`) //nolint:errcheck // Test server.
+	}))
+	defer ts.Close()
+
+	for _, test := range []struct {
+		name string
+		opts output.WebhookOptions
+		fail string
+	}{
+		{name: "status", opts: output.WebhookOptions{ExpectStatus: []string{"200"}}},
+		{name: "wrong status", opts: output.WebhookOptions{ExpectStatus: []string{"202"}}, fail: "unexpected status 200"},
+		{name: "body", opts: output.WebhookOptions{ExpectBodyRegex: `"status":"ok"`}},
+		{name: "wrong body", opts: output.WebhookOptions{ExpectBodyRegex: `error`}, fail: "body does not match"},
+		{name: "jsonpath value", opts: output.WebhookOptions{ExpectJSONPath: []string{"$.status=ok", "$.items[1].id=2"}}},
+		{name: "jsonpath exists", opts: output.WebhookOptions{ExpectJSONPath: []string{"$.items[-1]"}}},
+		{name: "wrong jsonpath value", opts: output.WebhookOptions{ExpectJSONPath: []string{"$.status=error"}}, fail: `$.status is "ok", expected "error"`},
+		{name: "missing jsonpath", opts: output.WebhookOptions{ExpectJSONPath: []string{"$.items[5]"}}, fail: "$.items[5] not found"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.Timeout = time.Second
+			out, err := New(&output.Options{Addr: ts.URL, WebhookOptions: test.opts})
+			require.NoError(t, err)
+
+			// Violations don't stop writes, they are reported when the
+			// output is closed.
+			for i := 0; i < 2; i++ {
+				_, err = out.Write([]byte(`{}`))
+				require.NoError(t, err)
+			}
+
+			err = out.Close()
+			if test.fail == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, "2 of 2 webhook responses failed assertions")
+			assert.ErrorContains(t, err, test.fail)
+		})
+	}
+}
+
+func TestWebhookSharedCapture(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	// Cloned outputs, like a reconnected output, count their responses
+	// together.
+	first, err := New(&output.Options{Addr: ts.URL, WebhookOptions: output.WebhookOptions{
+		Timeout:      time.Second,
+		AcceptStatus: []string{"500"},
+		ExpectStatus: []string{"200"},
+	}})
+	require.NoError(t, err)
+	second, err := first.(output.Cloner).Clone()
+	require.NoError(t, err)
+
+	_, err = first.Write([]byte(`{}`))
+	require.NoError(t, err)
+	require.NoError(t, first.Close())
+
+	_, err = second.Write([]byte(`{}`))
+	require.NoError(t, err)
+	assert.ErrorContains(t, second.Close(), "2 of 2 webhook responses failed assertions")
+}
+
+func TestParseJSONPath(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		want []jsonPathSegment
+		fail bool
+	}{
+		{path: "$"},
+		{path: "$.a.b", want: []jsonPathSegment{{key: "a", isKey: true}, {key: "b", isKey: true}}},
+		{path: "$.a[0]", want: []jsonPathSegment{{key: "a", isKey: true}, {index: 0}}},
+		{path: "$['a key'][-1]", want: []jsonPathSegment{{key: "a key", isKey: true}, {index: -1}}},
+		{path: "a.b", fail: true},
+		{path: "$.", fail: true},
+		{path: "$[x]", fail: true},
+		{path: "$[0", fail: true},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			got, err := parseJSONPath(test.path)
+			if test.fail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
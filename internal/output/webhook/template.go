@@ -0,0 +1,95 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/elastic/stream/internal/output"
+	"github.com/elastic/stream/internal/tmpl"
+)
+
+// event is the data passed to body and header templates. It prints as the raw
+// event, and the json template function embeds it as is if it is valid JSON or
+// as a JSON string otherwise.
+type event []byte
+
+func (e event) String() string {
+	return string(e)
+}
+
+func (e event) MarshalJSON() ([]byte, error) {
+	if json.Valid(e) {
+		return e, nil
+	}
+	return json.Marshal(string(e))
+}
+
+// templates holds the parsed body and header templates.
+type templates struct {
+	body    *template.Template
+	headers map[string]*template.Template
+}
+
+func newTemplates(opts output.WebhookOptions) (*templates, error) {
+	t := &templates{}
+	if opts.BodyTemplate != "" {
+		body, err := tmpl.Parse("body", opts.BodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse body template: %w", err)
+		}
+		t.body = body
+	}
+
+	headers, err := output.SplitKeyValues(opts.HeaderTemplates)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header template: %w", err)
+	}
+	for k, v := range headers {
+		h, err := tmpl.Parse(k, v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template of header %s: %w", k, err)
+		}
+		if t.headers == nil {
+			t.headers = map[string]*template.Template{}
+		}
+		t.headers[k] = h
+	}
+	return t, nil
+}
+
+// renderBody returns the event wrapped in the body template.
+func (t *templates) renderBody(b []byte) ([]byte, error) {
+	if t.body == nil {
+		return b, nil
+	}
+	var buf bytes.Buffer
+	if err := t.body.Execute(&buf, event(b)); err != nil {
+		return nil, fmt.Errorf("failed to execute body template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderHeaders returns the headers from the header templates executed with
+// the request body.
+func (t *templates) renderHeaders(body []byte) (http.Header, error) {
+	if len(t.headers) == 0 {
+		return nil, nil
+	}
+	header := make(http.Header, len(t.headers))
+	var buf bytes.Buffer
+	for k, h := range t.headers {
+		buf.Reset()
+		if err := h.Execute(&buf, event(body)); err != nil {
+			return nil, fmt.Errorf("failed to execute template of header %s: %w", k, err)
+		}
+		header.Set(k, buf.String())
+	}
+	return header, nil
+}
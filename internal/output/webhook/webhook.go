@@ -4,21 +4,23 @@
 
 // Package webhook provides an output that sends data to an HTTP or HTTPS
 // endpoint via configurable webhooks. It supports customizable HTTP headers,
-// basic authentication, custom content types, and configurable TLS settings for
-// secure communication. This package is intended to enable sending events or log
-// lines to web services that accept data over HTTP, often used for integrations
-// or alerting.
+// basic, bearer, OAuth2 client credentials, HMAC and AWS SigV4 authentication,
+// custom content types, and configurable TLS settings for secure communication.
+// This package is intended to enable sending events or log lines to web
+// services that accept data over HTTP, often used for integrations or alerting.
 package webhook
 
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/elastic/go-concert/timed"
 
 	"github.com/elastic/stream/internal/output"
 )
@@ -29,33 +31,128 @@ func init() {
 
 // Output is a webhook output.
 type Output struct {
-	opts   *output.Options
-	client *http.Client
+	opts     *output.Options
+	ctx      context.Context // Cancels the waits between retries.
+	client   *http.Client
+	auth     *authenticator
+	tmpl     *templates
+	capture  *capture
+	method   string
+	accept   func(code int) bool
+	encode   encoder // Nil if the body is not compressed.
+	encoding string  // Content-Encoding of compressed bodies.
 }
 
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+)
+
 // New returns a new webhook output.
 func New(opts *output.Options) (output.Output, error) {
+	o, err := newOutput(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.capture, err = newCapture(opts.WebhookOptions); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Clone returns a new webhook output that shares the response capture of o,
+// so that the responses to all of them are checked and counted once.
+func (o *Output) Clone() (output.Output, error) {
+	c, err := newOutput(o.opts)
+	if err != nil {
+		return nil, err
+	}
+	c.capture = o.capture.acquire()
+	return c, nil
+}
+
+// newOutput returns a new webhook output without a response capture.
+func newOutput(opts *output.Options) (*Output, error) {
 	if _, err := url.Parse(opts.Addr); err != nil {
 		return nil, fmt.Errorf("address must be a valid URL for webhook output: %w", err)
 	}
 
-	if opts.Timeout < 0 {
-		return nil, fmt.Errorf("timeout must not be negative: %v", opts.Timeout)
+	if opts.WebhookOptions.Timeout < 0 {
+		return nil, fmt.Errorf("timeout must not be negative: %v", opts.WebhookOptions.Timeout)
+	}
+	tlsConfig, err := opts.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, err := opts.ProxyURL()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		TLSClientConfig:     tlsConfig,
+		MaxConnsPerHost:     opts.WebhookOptions.MaxConns,
+		MaxIdleConnsPerHost: opts.WebhookOptions.MaxConns,
+	}
+	if transport.Protocols, err = protocols(opts.WebhookOptions.HTTPVersion, opts.Addr); err != nil {
+		return nil, err
+	}
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	auth, err := newAuthenticator(opts.WebhookOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.WebhookOptions.BatchFormat {
+	case "", BatchFormatNDJSON, BatchFormatJSONArray:
+	default:
+		return nil, fmt.Errorf("unknown webhook batch format %q (use %s or %s)", opts.WebhookOptions.BatchFormat, BatchFormatNDJSON, BatchFormatJSONArray)
 	}
+	encode, encoding, err := newEncoder(opts.WebhookOptions.Compression)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := newTemplates(opts.WebhookOptions)
+	if err != nil {
+		return nil, err
+	}
+	accept, err := parseAcceptStatus(opts.WebhookOptions.AcceptStatus)
+	if err != nil {
+		return nil, err
+	}
+	method := opts.WebhookOptions.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
 	client := &http.Client{
-		Timeout: opts.Timeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: opts.InsecureTLS,
-			},
-		},
+		Timeout:   opts.WebhookOptions.Timeout,
+		Transport: transport,
+	}
+	if opts.WebhookOptions.OAuth2TokenURL != "" {
+		if client.Transport, err = oauth2Transport(opts.WebhookOptions, transport, opts.WebhookOptions.Timeout); err != nil {
+			return nil, err
+		}
 	}
 
-	return &Output{opts: opts, client: client}, nil
+	return &Output{
+		opts:     opts,
+		client:   client,
+		auth:     auth,
+		tmpl:     templates,
+		method:   method,
+		accept:   accept,
+		ctx:      context.Background(),
+		encode:   encode,
+		encoding: encoding,
+	}, nil
 }
 
 // DialContext connects to the configured endpoint.
 func (o *Output) DialContext(ctx context.Context) error {
+	o.ctx = ctx
+
 	method := o.opts.WebhookOptions.Probe
 	switch method {
 	case "", "1", "true", http.MethodHead:
@@ -76,10 +173,10 @@ func (o *Output) DialContext(ctx context.Context) error {
 		return err
 	}
 
-	if o.opts.Username != "" && o.opts.Password != "" {
-		req.SetBasicAuth(o.opts.Username, o.opts.Password)
+	if err = setHeaders(req, o.opts.WebhookOptions.Headers); err != nil {
+		return err
 	}
-	if err = setHeaders(req, o.opts.Headers); err != nil {
+	if err = o.auth.authorize(req, nil); err != nil {
 		return err
 	}
 
@@ -96,45 +193,169 @@ func (o *Output) DialContext(ctx context.Context) error {
 // Close closes the connection to the configured endpoint.
 func (o *Output) Close() error {
 	o.client.CloseIdleConnections()
-	return nil
+	return o.capture.Close()
 }
 
 // Write writes data to the configured endpoint.
 func (o *Output) Write(b []byte) (int, error) {
-	req, err := http.NewRequest(http.MethodPost, o.opts.Addr, bytes.NewReader(b))
+	body, err := o.tmpl.renderBody(b)
 	if err != nil {
 		return 0, err
 	}
+	if err = o.post(body); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
 
-	if o.opts.ContentType != "" {
-		req.Header.Set("Content-Type", o.opts.ContentType)
+// WriteBatch implements output.BatchOutput. The events are sent in a single
+// request as newline delimited JSON or as a JSON array.
+func (o *Output) WriteBatch(batch [][]byte) error {
+	if o.tmpl.body != nil {
+		rendered := make([][]byte, len(batch))
+		for i, b := range batch {
+			var err error
+			if rendered[i], err = o.tmpl.renderBody(b); err != nil {
+				return err
+			}
+		}
+		batch = rendered
 	}
-	if o.opts.Username != "" && o.opts.Password != "" {
-		req.SetBasicAuth(o.opts.Username, o.opts.Password)
+	return o.post(joinBatch(batch, o.opts.WebhookOptions.BatchFormat))
+}
+
+// post sends body to the configured endpoint. Requests answered with 429 or
+// 503 are retried with backoff, or after the wait given in Retry-After.
+func (o *Output) post(body []byte) error {
+	header, err := o.tmpl.renderHeaders(body)
+	if err != nil {
+		return err
 	}
-	if err = setHeaders(req, o.opts.Headers); err != nil {
-		return 0, err
+	if o.encode != nil {
+		if body, err = o.encode(body); err != nil {
+			return fmt.Errorf("failed to compress request body: %w", err)
+		}
+	}
+
+	wopts := o.opts.WebhookOptions
+	initial, maxBackoff := wopts.RetryBackoff, wopts.RetryMaxBackoff
+	if initial <= 0 {
+		initial = defaultRetryBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		req, resp, respBody, err := o.send(body, header)
+		if err != nil {
+			return err
+		}
+
+		accepted := o.accept(resp.StatusCode)
+		retry := !accepted && isRetryable(resp.StatusCode) && attempt < wopts.RetryMax
+		if err = o.capture.record(req, attempt, resp, respBody, time.Since(start), !retry); err != nil {
+			return err
+		}
+		if accepted {
+			return nil
+		}
+
+		if retry {
+			wait, ok := retryAfter(resp.Header, time.Now())
+			if !ok {
+				wait = output.Backoff(initial, maxBackoff, attempt)
+			}
+			if err = timed.Wait(o.ctx, min(wait, maxBackoff)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(respBody) == 0 {
+			respBody = []byte("no body")
+		}
+		return fmt.Errorf("http %s to webhook failed with http status %v %v: %s", strings.ToLower(o.method), resp.StatusCode, resp.Status, respBody)
+	}
+}
+
+// send sends a request with body and the templated headers, and returns the
+// request, the response and its body.
+func (o *Output) send(body []byte, header http.Header) (*http.Request, *http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(o.ctx, o.method, o.opts.Addr, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if o.opts.WebhookOptions.ContentType != "" {
+		req.Header.Set("Content-Type", o.opts.WebhookOptions.ContentType)
+	}
+	if o.encoding != "" {
+		req.Header.Set("Content-Encoding", o.encoding)
+	}
+	if err = setHeaders(req, o.opts.WebhookOptions.Headers); err != nil {
+		return nil, nil, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err = o.auth.authorize(req, body); err != nil {
+		return nil, nil, nil, err
 	}
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return 0, err
+		return nil, nil, nil, err
 	}
 	defer resp.Body.Close()
 
-	var buf bytes.Buffer
-	if _, err = io.Copy(&buf, resp.Body); err != nil {
-		return 0, fmt.Errorf("failed to read response body: %w", err)
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return req, resp, respBody, nil
+}
+
+// HTTP versions.
+const (
+	HTTPVersionAuto = "auto" // HTTP/2 if negotiated with TLS ALPN, otherwise HTTP/1.1.
+	HTTPVersion1    = "1.1"  // HTTP/1.1 only.
+	HTTPVersion2    = "2"    // HTTP/2 over TLS only.
+	HTTPVersionH2C  = "h2c"  // HTTP/2 without TLS using prior knowledge.
+)
+
+// protocols returns the protocols that the transport uses for the HTTP
+// version. A transport with a custom TLS configuration does not attempt HTTP/2
+// unless the protocols are set.
+func protocols(version, addr string) (*http.Protocols, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
 	}
+	secure := u.Scheme == "https"
 
-	if resp.StatusCode != http.StatusOK {
-		if buf.Len() == 0 {
-			buf.WriteString("no body")
+	p := new(http.Protocols)
+	switch version {
+	case "", HTTPVersionAuto:
+		p.SetHTTP1(true)
+		p.SetHTTP2(true)
+	case HTTPVersion1:
+		p.SetHTTP1(true)
+	case HTTPVersion2:
+		if !secure {
+			return nil, fmt.Errorf("webhook http version %s requires an https address, use %s for http", HTTPVersion2, HTTPVersionH2C)
+		}
+		p.SetHTTP2(true)
+	case HTTPVersionH2C:
+		if secure {
+			return nil, fmt.Errorf("webhook http version %s requires an http address, use %s for https", HTTPVersionH2C, HTTPVersion2)
 		}
-		return 0, fmt.Errorf("http post to webhook failed with http status %v %v: %s", resp.StatusCode, resp.Status, &buf)
+		p.SetUnencryptedHTTP2(true)
+	default:
+		return nil, fmt.Errorf("unknown webhook http version %q (use %s, %s, %s or %s)", version, HTTPVersionAuto, HTTPVersion1, HTTPVersion2, HTTPVersionH2C)
 	}
-
-	return len(b), nil
+	return p, nil
 }
 
 func setHeaders(req *http.Request, headers []string) error {
@@ -5,15 +5,21 @@
 package webhook
 
 import (
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -126,3 +132,366 @@ func TestWebhookProbe(t *testing.T) {
 		})
 	}
 }
+
+func TestWebhookWriteBatch(t *testing.T) {
+	bodies := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			data, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			bodies <- string(data)
+		}
+	}))
+	defer ts.Close()
+
+	out, err := New(&output.Options{
+		Addr:           ts.URL + "/logs",
+		WebhookOptions: output.WebhookOptions{Timeout: time.Second, Probe: "false"},
+	})
+	require.NoError(t, err)
+
+	err = out.(output.BatchOutput).WriteBatch([][]byte{[]byte(`{"a":1}`), []byte(`{"b":2}`)})
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":1}\n{\"b\":2}", <-bodies)
+}
+
+func TestWebhookProxy(t *testing.T) {
+	var target string
+	proxy := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		// Requests sent through a forward proxy use the absolute URL.
+		target = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	out, err := New(&output.Options{
+		Addr:           "http://logs.example.com/ingest",
+		Proxy:          proxy.URL,
+		WebhookOptions: output.WebhookOptions{Timeout: time.Second, Probe: "false"},
+	})
+	require.NoError(t, err)
+
+	_, err = out.Write([]byte(`{"message":"hello"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "http://logs.example.com/ingest", target)
+}
+
+func TestWebhookBatchFormat(t *testing.T) {
+	bodies := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bodies <- string(data)
+	}))
+	defer ts.Close()
+
+	out, err := New(&output.Options{
+		Addr: ts.URL,
+		WebhookOptions: output.WebhookOptions{
+			Timeout:     time.Second,
+			BatchFormat: BatchFormatJSONArray,
+		},
+	})
+	require.NoError(t, err)
+
+	err = out.(output.BatchOutput).WriteBatch([][]byte{[]byte(`{"a":1}`), []byte(`{"b":2}`)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"a":1},{"b":2}]`, <-bodies)
+
+	_, err = New(&output.Options{Addr: ts.URL, WebhookOptions: output.WebhookOptions{BatchFormat: "xml"}})
+	assert.Error(t, err)
+}
+
+func TestWebhookCompression(t *testing.T) {
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"deflate": func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+		"zstd":    func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+
+	for compression, decode := range decoders {
+		t.Run(compression, func(t *testing.T) {
+			bodies := make(chan string, 1)
+			ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, compression, r.Header.Get("Content-Encoding"))
+				assert.Equal(t, http.MethodPut, r.Method)
+				dec, err := decode(r.Body)
+				if !assert.NoError(t, err) {
+					return
+				}
+				data, err := io.ReadAll(dec)
+				assert.NoError(t, err)
+				bodies <- string(data)
+			}))
+			defer ts.Close()
+
+			out, err := New(&output.Options{
+				Addr: ts.URL,
+				WebhookOptions: output.WebhookOptions{
+					Timeout:     time.Second,
+					Method:      http.MethodPut,
+					Compression: compression,
+				},
+			})
+			require.NoError(t, err)
+
+			_, err = out.Write([]byte(`{"message":"hello"}`))
+			require.NoError(t, err)
+			assert.Equal(t, `{"message":"hello"}`, <-bodies)
+		})
+	}
+}
+
+func TestWebhookAcceptStatus(t *testing.T) {
+	for _, test := range []struct {
+		status int
+		accept []string
+		ok     bool
+	}{
+		{status: http.StatusOK, ok: true},
+		{status: http.StatusAccepted, ok: true},
+		{status: http.StatusNoContent, accept: []string{"2xx"}, ok: true},
+		{status: http.StatusCreated, accept: []string{"202", "204"}, ok: false},
+		{status: http.StatusNoContent, accept: []string{"202", "204"}, ok: true},
+		{status: http.StatusNotFound, accept: []string{"2xx", "404"}, ok: true},
+		{status: http.StatusBadRequest, ok: false},
+	} {
+		t.Run(fmt.Sprint(test.status, test.accept), func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(test.status)
+			}))
+			defer ts.Close()
+
+			out, err := New(&output.Options{
+				Addr:           ts.URL,
+				WebhookOptions: output.WebhookOptions{Timeout: time.Second, AcceptStatus: test.accept},
+			})
+			require.NoError(t, err)
+
+			_, err = out.Write([]byte(`{}`))
+			if test.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+
+	_, err := New(&output.Options{Addr: "http://localhost", WebhookOptions: output.WebhookOptions{AcceptStatus: []string{"2yy"}}})
+	assert.Error(t, err)
+}
+
+func TestWebhookRetry(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer ts.Close()
+
+	opts := &output.Options{
+		Addr: ts.URL,
+		WebhookOptions: output.WebhookOptions{
+			Timeout:      time.Second,
+			RetryMax:     2,
+			RetryBackoff: time.Millisecond,
+		},
+	}
+	out, err := New(opts)
+	require.NoError(t, err)
+
+	_, err = out.Write([]byte(`{}`))
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, requests.Load())
+
+	// Retries are exhausted.
+	requests.Store(0)
+	opts.WebhookOptions.RetryMax = 1
+	out, err = New(opts)
+	require.NoError(t, err)
+
+	_, err = out.Write([]byte(`{}`))
+	assert.ErrorContains(t, err, "503")
+	assert.EqualValues(t, 2, requests.Load())
+}
+
+func TestWebhookRetryCanceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	out, err := New(&output.Options{
+		Addr: ts.URL,
+		WebhookOptions: output.WebhookOptions{
+			Probe:           "false",
+			RetryMax:        1,
+			RetryMaxBackoff: time.Minute,
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, out.DialContext(ctx))
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err = out.Write([]byte(`{}`))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestWebhookRequestCanceled(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	out, err := New(&output.Options{
+		Addr:           ts.URL,
+		WebhookOptions: output.WebhookOptions{Probe: "false"},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, out.DialContext(ctx))
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	// The request in flight is canceled with the output's context.
+	_, err = out.Write([]byte(`{}`))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	wait, ok := retryAfter(http.Header{"Retry-After": {"3"}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, wait)
+
+	wait, ok = retryAfter(http.Header{"Retry-After": {now.Add(time.Minute).Format(http.TimeFormat)}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, wait)
+
+	_, ok = retryAfter(http.Header{"Retry-After": {"soon"}}, now)
+	assert.False(t, ok)
+	_, ok = retryAfter(http.Header{}, now)
+	assert.False(t, ok)
+}
+
+func TestWebhookTemplates(t *testing.T) {
+	type request struct {
+		body      string
+		requestID string
+	}
+	requests := make(chan request, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		requests <- request{body: string(data), requestID: r.Header.Get("X-Request-ID")}
+	}))
+	defer ts.Close()
+
+	out, err := New(&output.Options{
+		Addr: ts.URL,
+		WebhookOptions: output.WebhookOptions{
+			Timeout:         time.Second,
+			BodyTemplate:    `{"event": {{ . | json }}, "year": "{{ (now).Format "2006" }}"}`,
+			HeaderTemplates: []string{"X-Request-ID={{ uuid }}"},
+		},
+	})
+	require.NoError(t, err)
+
+	year := time.Now().UTC().Format("2006")
+
+	_, err = out.Write([]byte(`{"message":"hello"}`))
+	require.NoError(t, err)
+	first := <-requests
+	assert.JSONEq(t, `{"event": {"message":"hello"}, "year": "`+year+`"}`, first.body)
+
+	// Lines that are not JSON are embedded as strings.
+	_, err = out.Write([]byte(`plain "text"`))
+	require.NoError(t, err)
+	second := <-requests
+	assert.JSONEq(t, `{"event": "plain \"text\"", "year": "`+year+`"}`, second.body)
+
+	assert.Len(t, first.requestID, 36)
+	assert.NotEqual(t, first.requestID, second.requestID)
+
+	_, err = New(&output.Options{Addr: ts.URL, WebhookOptions: output.WebhookOptions{BodyTemplate: "{{ ."}})
+	assert.Error(t, err)
+}
+
+func TestWebhookHTTPVersion(t *testing.T) {
+	newServer := func(tls bool) *httptest.Server {
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Proto", r.Proto)
+		}))
+		ts.Config.Protocols = new(http.Protocols)
+		ts.Config.Protocols.SetHTTP1(true)
+		ts.Config.Protocols.SetHTTP2(true)
+		ts.Config.Protocols.SetUnencryptedHTTP2(true)
+		if tls {
+			ts.EnableHTTP2 = true
+			ts.StartTLS()
+		} else {
+			ts.Start()
+		}
+		t.Cleanup(ts.Close)
+		return ts
+	}
+	httpServer, httpsServer := newServer(false), newServer(true)
+
+	for _, test := range []struct {
+		version string
+		server  *httptest.Server
+		proto   string
+	}{
+		{version: "", server: httpsServer, proto: "HTTP/2.0"},
+		{version: HTTPVersionAuto, server: httpServer, proto: "HTTP/1.1"},
+		{version: HTTPVersion1, server: httpsServer, proto: "HTTP/1.1"},
+		{version: HTTPVersion2, server: httpsServer, proto: "HTTP/2.0"},
+		{version: HTTPVersionH2C, server: httpServer, proto: "HTTP/2.0"},
+	} {
+		t.Run(test.version+" "+test.server.URL, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "responses.jsonl")
+			out, err := New(&output.Options{
+				Addr:        test.server.URL,
+				InsecureTLS: true,
+				WebhookOptions: output.WebhookOptions{
+					Timeout:      time.Second,
+					HTTPVersion:  test.version,
+					MaxConns:     2,
+					ResponseFile: path,
+				},
+			})
+			require.NoError(t, err)
+
+			_, err = out.Write([]byte(`{}`))
+			require.NoError(t, err)
+			require.NoError(t, out.Close())
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			var r responseRecord
+			require.NoError(t, json.Unmarshal(data, &r))
+			assert.Equal(t, test.proto, r.Headers.Get("X-Proto"))
+		})
+	}
+
+	_, err := New(&output.Options{Addr: httpServer.URL, WebhookOptions: output.WebhookOptions{HTTPVersion: HTTPVersion2}})
+	assert.Error(t, err)
+	_, err = New(&output.Options{Addr: httpsServer.URL, WebhookOptions: output.WebhookOptions{HTTPVersion: HTTPVersionH2C}})
+	assert.Error(t, err)
+	_, err = New(&output.Options{Addr: httpsServer.URL, WebhookOptions: output.WebhookOptions{HTTPVersion: "3"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,229 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package websocket provides an output that sends each event as a message over
+// a WebSocket connection to a ws:// or wss:// URL. It supports custom handshake
+// headers, subprotocols, text or binary messages and waiting for the server to
+// acknowledge each message.
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+func init() {
+	output.Register("websocket", New)
+}
+
+// defaultHandshakeTimeout is the time to wait for the opening handshake when
+// no handshake timeout is set.
+const defaultHandshakeTimeout = 30 * time.Second
+
+// Message types.
+const (
+	MessageText   = "text"
+	MessageBinary = "binary"
+)
+
+// Output is a WebSocket output.
+type Output struct {
+	opts        *output.Options
+	header      http.Header
+	messageType int
+	ack         *regexp.Regexp // Nil if messages are not acked.
+	conn        *websocket.Conn
+	acks        chan struct{} // Acks received by the reader.
+	done        chan struct{} // Closed when the reader returns.
+
+	mu  sync.Mutex
+	err error // Error that makes the connection unusable.
+}
+
+// New returns a new WebSocket output.
+func New(opts *output.Options) (output.Output, error) {
+	u, err := url.Parse(opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("address must be a valid URL for websocket output: %w", err)
+	}
+	switch u.Scheme {
+	case "ws", "wss":
+	default:
+		return nil, fmt.Errorf("invalid websocket url scheme %q (use ws or wss)", u.Scheme)
+	}
+
+	headers, err := output.SplitKeyValues(opts.WebhookOptions.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	header := make(http.Header, len(headers))
+	for k, v := range headers {
+		header.Set(k, v)
+	}
+
+	o := &Output{opts: opts, header: header}
+	switch opts.WebSocketOptions.MessageType {
+	case "", MessageText:
+		o.messageType = websocket.TextMessage
+	case MessageBinary:
+		o.messageType = websocket.BinaryMessage
+	default:
+		return nil, fmt.Errorf("unknown websocket message type %q (use %s or %s)", opts.WebSocketOptions.MessageType, MessageText, MessageBinary)
+	}
+	if opts.WebSocketOptions.AckPattern != "" {
+		if o.ack, err = regexp.Compile(opts.WebSocketOptions.AckPattern); err != nil {
+			return nil, fmt.Errorf("invalid websocket ack pattern: %w", err)
+		}
+	}
+	return o, nil
+}
+
+// DialContext connects to the configured endpoint and performs the opening
+// handshake.
+func (o *Output) DialContext(ctx context.Context) error {
+	tlsConfig, err := o.opts.TLSConfig()
+	if err != nil {
+		return err
+	}
+	dial, err := o.opts.Dialer(&net.Dialer{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+
+	handshakeTimeout := o.opts.WebSocketOptions.HandshakeTimeout
+	if handshakeTimeout <= 0 {
+		handshakeTimeout = defaultHandshakeTimeout
+	}
+	dialer := &websocket.Dialer{
+		NetDialContext:   dial,
+		TLSClientConfig:  tlsConfig,
+		Subprotocols:     o.opts.WebSocketOptions.Subprotocols,
+		HandshakeTimeout: handshakeTimeout,
+	}
+	conn, resp, err := dialer.DialContext(ctx, o.opts.Addr, o.header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("websocket handshake failed with http status %v: %w", resp.Status, err)
+		}
+		return err
+	}
+	resp.Body.Close()
+	o.conn = conn
+	o.acks = make(chan struct{}, 1)
+	o.done = make(chan struct{})
+	go o.read()
+	return nil
+}
+
+// Close sends a close message, waits briefly for the server to close the
+// connection and closes it.
+func (o *Output) Close() error {
+	if o.conn == nil {
+		return nil
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	err := o.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	if errors.Is(err, websocket.ErrCloseSent) {
+		err = nil
+	}
+	if err == nil {
+		select {
+		case <-o.done:
+		case <-time.After(time.Second):
+		}
+	}
+	err = errors.Join(err, o.conn.Close())
+	<-o.done
+	return err
+}
+
+// Write sends b as one message. If an ack pattern is configured it waits for a
+// server message that matches it.
+func (o *Output) Write(b []byte) (int, error) {
+	if o.conn == nil {
+		return 0, errors.New("not connected")
+	}
+	if err := o.firstErr(); err != nil {
+		return 0, err
+	}
+
+	if err := o.conn.WriteMessage(o.messageType, b); err != nil {
+		return 0, err
+	}
+
+	if o.ack != nil {
+		if err := o.waitForAck(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (o *Output) waitForAck() error {
+	var timeout <-chan time.Time
+	if d := o.opts.WebSocketOptions.AckTimeout; d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-o.acks:
+		return nil
+	case <-o.done:
+		return fmt.Errorf("failed waiting for websocket ack: %w", o.firstErr())
+	case <-timeout:
+		// A late ack would be taken for the ack of the next message, so the
+		// connection can't be used anymore.
+		err := fmt.Errorf("failed waiting for websocket ack: no ack after %v", o.opts.WebSocketOptions.AckTimeout)
+		o.setErr(err)
+		return err
+	}
+}
+
+// read reads the server messages until the connection fails or is closed.
+// Reading is what answers pings and close messages, so it runs even when
+// messages are not acked. Messages that are not acks are discarded.
+func (o *Output) read() {
+	defer close(o.done)
+	for {
+		_, msg, err := o.conn.ReadMessage()
+		if err != nil {
+			o.setErr(fmt.Errorf("websocket connection failed: %w", err))
+			return
+		}
+		if o.ack != nil && o.ack.Match(msg) {
+			select {
+			case o.acks <- struct{}{}:
+			default:
+				// An unexpected ack while one is already pending.
+			}
+		}
+	}
+}
+
+func (o *Output) setErr(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+func (o *Output) firstErr() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.err
+}
@@ -0,0 +1,239 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+type message struct {
+	typ  int
+	data string
+}
+
+// startServer starts a WebSocket server that records the received messages
+// and replies to each one with a noise message followed by "ack <n>".
+func startServer(t *testing.T, tls bool) (*httptest.Server, <-chan message, <-chan *http.Request) {
+	t.Helper()
+
+	messages := make(chan message, 10)
+	requests := make(chan *http.Request, 1)
+	upgrader := websocket.Upgrader{Subprotocols: []string{"events.v1"}}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		for n := 1; ; n++ {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			messages <- message{typ: typ, data: string(data)}
+			if err = conn.WriteMessage(websocket.TextMessage, []byte("noise")); err != nil {
+				return
+			}
+			if err = conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprint("ack ", n))); err != nil {
+				return
+			}
+		}
+	})
+
+	ts := httptest.NewUnstartedServer(handler)
+	if tls {
+		ts.StartTLS()
+	} else {
+		ts.Start()
+	}
+	t.Cleanup(ts.Close)
+	return ts, messages, requests
+}
+
+func wsURL(ts *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func TestWebSocket(t *testing.T) {
+	for _, tls := range []bool{false, true} {
+		t.Run(fmt.Sprint("tls=", tls), func(t *testing.T) {
+			ts, messages, requests := startServer(t, tls)
+
+			out, err := New(&output.Options{
+				Addr:           wsURL(ts),
+				InsecureTLS:    true,
+				WebhookOptions: output.WebhookOptions{Headers: []string{"Authorization=Bearer token"}},
+				WebSocketOptions: output.WebSocketOptions{
+					Subprotocols: []string{"events.v1"},
+					AckPattern:   `^ack \d+$`,
+					AckTimeout:   time.Second,
+				},
+			})
+			require.NoError(t, err)
+			require.NoError(t, out.DialContext(context.Background()))
+
+			r := <-requests
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			assert.Equal(t, "events.v1", r.Header.Get("Sec-Websocket-Protocol"))
+
+			for _, event := range []string{`{"a":1}`, `{"b":2}`} {
+				n, err := out.Write([]byte(event))
+				require.NoError(t, err)
+				assert.Equal(t, len(event), n)
+				assert.Equal(t, message{typ: websocket.TextMessage, data: event}, <-messages)
+			}
+			require.NoError(t, out.Close())
+		})
+	}
+}
+
+func TestWebSocketBinary(t *testing.T) {
+	ts, messages, _ := startServer(t, false)
+
+	out, err := New(&output.Options{
+		Addr:             wsURL(ts),
+		WebSocketOptions: output.WebSocketOptions{MessageType: MessageBinary},
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.DialContext(context.Background()))
+	defer out.Close()
+
+	_, err = out.Write([]byte("data"))
+	require.NoError(t, err)
+	assert.Equal(t, message{typ: websocket.BinaryMessage, data: "data"}, <-messages)
+}
+
+func TestWebSocketAckTimeout(t *testing.T) {
+	ts, _, _ := startServer(t, false)
+
+	out, err := New(&output.Options{
+		Addr: wsURL(ts),
+		WebSocketOptions: output.WebSocketOptions{
+			AckPattern: "never",
+			AckTimeout: 100 * time.Millisecond,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.DialContext(context.Background()))
+	defer out.Close()
+
+	_, err = out.Write([]byte("data"))
+	assert.ErrorContains(t, err, "failed waiting for websocket ack")
+
+	// Later writes fail without using the connection again.
+	for range 2000 {
+		_, err = out.Write([]byte("data"))
+		assert.ErrorContains(t, err, "failed waiting for websocket ack")
+	}
+}
+
+func TestWebSocketPing(t *testing.T) {
+	pongs := make(chan string, 1)
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		conn.SetPongHandler(func(data string) error {
+			pongs <- data
+			return nil
+		})
+		if !assert.NoError(t, conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(time.Second))) {
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	// Pings are answered without an ack pattern.
+	out, err := New(&output.Options{Addr: wsURL(ts)})
+	require.NoError(t, err)
+	require.NoError(t, out.DialContext(context.Background()))
+	defer out.Close()
+
+	select {
+	case data := <-pongs:
+		assert.Equal(t, "ping", data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pong received")
+	}
+}
+
+func TestWebSocketServerClose(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "restart")
+		assert.NoError(t, conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)))
+	}))
+	defer ts.Close()
+
+	out, err := New(&output.Options{Addr: wsURL(ts)})
+	require.NoError(t, err)
+	require.NoError(t, out.DialContext(context.Background()))
+	defer out.Close()
+
+	// The close message is read in the background, and writes report it.
+	assert.Eventually(t, func() bool {
+		_, err := out.Write([]byte("data"))
+		return err != nil && strings.Contains(err.Error(), "going away")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWebSocketHandshakeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	out, err := New(&output.Options{
+		Addr:             wsURL(ts),
+		WebSocketOptions: output.WebSocketOptions{HandshakeTimeout: 50 * time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	assert.Error(t, out.DialContext(context.Background()))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestNew(t *testing.T) {
+	for _, opts := range []*output.Options{
+		{Addr: "http://localhost"},
+		{Addr: "ws://localhost", WebSocketOptions: output.WebSocketOptions{MessageType: "json"}},
+		{Addr: "ws://localhost", WebSocketOptions: output.WebSocketOptions{AckPattern: "("}},
+		{Addr: "ws://localhost", WebhookOptions: output.WebhookOptions{Headers: []string{"bad"}}},
+	} {
+		_, err := New(opts)
+		assert.Error(t, err, "%+v", opts)
+	}
+}
@@ -0,0 +1,188 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Worker distributions.
+const (
+	DistributionRoundRobin = "roundrobin" // Events are sent to each worker in turn.
+	DistributionHash       = "hash"       // Events with the same key are sent to the same worker.
+)
+
+// workerQueueSize is the number of events buffered for each worker.
+const workerQueueSize = 100
+
+// WorkerOptions holds configuration for writing to several outputs
+// concurrently.
+type WorkerOptions struct {
+	Workers      int    `config:"workers"`        // Number of outputs written to concurrently.
+	Distribution string `config:"distribution"`   // How events are distributed to the workers (roundrobin or hash).
+	HashKeyField string `config:"hash_key_field"` // Dotted path of the JSON field hashed by the hash distribution.
+}
+
+// workerPool is an Output that distributes events across several outputs,
+// each written to by its own goroutine.
+type workerPool struct {
+	workers  []*worker
+	hash     bool
+	keyField string
+	next     int // Next worker for round-robin distribution.
+}
+
+// workItem is either an event or the start of a new source.
+type workItem struct {
+	data   []byte
+	source string
+	start  bool // Start a new source instead of writing data.
+}
+
+type worker struct {
+	out  Output
+	ch   chan workItem
+	done chan struct{}
+
+	mu  sync.Mutex
+	err error // First write error.
+}
+
+func newWorkerPool(ctx context.Context, opts *Options, logger *zap.SugaredLogger) (*workerPool, error) {
+	wopts := opts.WorkerOptions
+
+	p := &workerPool{keyField: wopts.HashKeyField}
+	switch wopts.Distribution {
+	case "", DistributionRoundRobin:
+	case DistributionHash:
+		if wopts.HashKeyField == "" {
+			return nil, errors.New("hash distribution requires a key field")
+		}
+		p.hash = true
+	default:
+		return nil, fmt.Errorf("unknown worker distribution %q (use %s or %s)", wopts.Distribution, DistributionRoundRobin, DistributionHash)
+	}
+
+	var first Output
+	for i := 0; i < wopts.Workers; i++ {
+		// The outputs of the other workers are cloned from the first, so that
+		// they share its state.
+		var (
+			o   Output
+			err error
+		)
+		if first == nil {
+			o, err = newOutput(opts, logger)
+		} else {
+			o, err = cloneOutput(first, opts, logger)
+		}
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to create worker %d: %w", i, err), p.Close())
+		}
+		if first == nil {
+			first = o
+		}
+
+		out, err := dial(ctx, opts, o, logger.With("worker", i))
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to connect worker %d: %w", i, err), p.Close())
+		}
+
+		w := &worker{out: out, ch: make(chan workItem, workerQueueSize), done: make(chan struct{})}
+		go w.run()
+		p.workers = append(p.workers, w)
+	}
+
+	return p, nil
+}
+
+// DialContext is a no-op because the workers are connected when the pool is
+// created.
+func (*workerPool) DialContext(context.Context) error {
+	return nil
+}
+
+// Write queues b for one of the workers. An error from an earlier write by any
+// worker is returned.
+func (p *workerPool) Write(b []byte) (int, error) {
+	for _, w := range p.workers {
+		if err := w.firstErr(); err != nil {
+			return 0, err
+		}
+	}
+
+	var w *worker
+	if p.hash {
+		key, _ := JSONField(b, p.keyField)
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		w = p.workers[h.Sum32()%uint32(len(p.workers))]
+	} else {
+		w = p.workers[p.next]
+		p.next = (p.next + 1) % len(p.workers)
+	}
+
+	// The caller may reuse b, so the worker needs its own copy.
+	w.ch <- workItem{data: bytes.Clone(b)}
+	return len(b), nil
+}
+
+// StartSource forwards path to every worker, after the events already queued.
+func (p *workerPool) StartSource(path string) error {
+	for _, w := range p.workers {
+		w.ch <- workItem{source: path, start: true}
+	}
+	return nil
+}
+
+// Close waits for the workers to write the queued events and closes their
+// outputs.
+func (p *workerPool) Close() error {
+	var err error
+	for _, w := range p.workers {
+		close(w.ch)
+	}
+	for _, w := range p.workers {
+		<-w.done
+		err = errors.Join(err, w.firstErr(), w.out.Close())
+	}
+	return err
+}
+
+// run writes the queued items. After an error the remaining items are
+// discarded so that the pool never blocks on a failed worker.
+func (w *worker) run() {
+	defer close(w.done)
+	for item := range w.ch {
+		if w.firstErr() != nil {
+			continue
+		}
+
+		var err error
+		if item.start {
+			err = StartSource(w.out, item.source)
+		} else {
+			_, err = w.out.Write(item.data)
+		}
+		if err != nil {
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+		}
+	}
+}
+
+func (w *worker) firstErr() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
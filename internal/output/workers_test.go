@@ -0,0 +1,216 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingOutput records the events and sources written to it.
+type recordingOutput struct {
+	mu      sync.Mutex
+	events  []string
+	sources []string
+	dialed  bool
+	closed  bool
+	failOn  string // Event that fails to write.
+}
+
+func (o *recordingOutput) DialContext(context.Context) error {
+	o.dialed = true
+	return nil
+}
+
+func (o *recordingOutput) Write(b []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.failOn != "" && string(b) == o.failOn {
+		return 0, errors.New("write failed")
+	}
+	o.events = append(o.events, string(b))
+	return len(b), nil
+}
+
+func (o *recordingOutput) StartSource(path string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sources = append(o.sources, path)
+	return nil
+}
+
+func (o *recordingOutput) Close() error {
+	o.closed = true
+	return nil
+}
+
+// registerRecordingOutput registers a protocol whose outputs are appended to
+// the returned slice as they are created.
+func registerRecordingOutput(t *testing.T, failOn string) *[]*recordingOutput {
+	t.Helper()
+
+	var outputs []*recordingOutput
+	Register("recording-test", func(*Options) (Output, error) {
+		o := &recordingOutput{failOn: failOn}
+		outputs = append(outputs, o)
+		return o, nil
+	})
+	t.Cleanup(func() { delete(registry, "recording-test") })
+	return &outputs
+}
+
+func TestWorkersRoundRobin(t *testing.T) {
+	outputs := registerRecordingOutput(t, "")
+
+	out, err := Initialize(context.Background(), &Options{
+		Protocol:      "recording-test",
+		Retries:       1,
+		WorkerOptions: WorkerOptions{Workers: 3},
+	}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	require.NoError(t, StartSource(out, "a.log"))
+	buf := make([]byte, 1)
+	for i := 0; i < 6; i++ {
+		// Reusing the buffer must not change queued events.
+		buf[0] = byte('0' + i)
+		_, err = out.Write(buf)
+		require.NoError(t, err)
+	}
+	require.NoError(t, out.Close())
+
+	require.Len(t, *outputs, 3)
+	for i, o := range *outputs {
+		assert.True(t, o.dialed)
+		assert.True(t, o.closed)
+		assert.Equal(t, []string{"a.log"}, o.sources)
+		assert.Equal(t, []string{fmt.Sprint(i), fmt.Sprint(i + 3)}, o.events)
+	}
+}
+
+func TestWorkersHash(t *testing.T) {
+	outputs := registerRecordingOutput(t, "")
+
+	out, err := Initialize(context.Background(), &Options{
+		Protocol:      "recording-test",
+		Retries:       1,
+		WorkerOptions: WorkerOptions{Workers: 4, Distribution: DistributionHash, HashKeyField: "user.id"},
+	}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err = out.Write([]byte(fmt.Sprintf(`{"user":{"id":%d},"seq":%d}`, i%5, i)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, out.Close())
+
+	// Each key is written to a single worker, in order.
+	workerForKey := map[string]int{}
+	var total int
+	for i, o := range *outputs {
+		prevSeq := map[string]int{}
+		for _, event := range o.events {
+			key, _ := JSONField([]byte(event), "user.id")
+			if w, found := workerForKey[key]; found {
+				assert.Equal(t, w, i, "key %s written by several workers", key)
+			}
+			workerForKey[key] = i
+
+			seq, _ := JSONField([]byte(event), "seq")
+			var n int
+			fmt.Sscan(seq, &n)
+			if prev, found := prevSeq[key]; found {
+				assert.Greater(t, n, prev, "key %s out of order", key)
+			}
+			prevSeq[key] = n
+			total++
+		}
+	}
+	assert.Equal(t, 100, total)
+	assert.Len(t, workerForKey, 5)
+}
+
+func TestWorkersError(t *testing.T) {
+	registerRecordingOutput(t, "bad")
+
+	out, err := Initialize(context.Background(), &Options{
+		Protocol:      "recording-test",
+		Retries:       1,
+		WorkerOptions: WorkerOptions{Workers: 2},
+	}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	_, err = out.Write([]byte("bad"))
+	require.NoError(t, err)
+
+	// The error is reported by a later write or by Close.
+	assert.Error(t, out.Close())
+}
+
+func TestWorkersInvalidDistribution(t *testing.T) {
+	registerRecordingOutput(t, "")
+
+	for _, wopts := range []WorkerOptions{
+		{Workers: 2, Distribution: "random"},
+		{Workers: 2, Distribution: DistributionHash},
+	} {
+		_, err := Initialize(context.Background(), &Options{
+			Protocol:      "recording-test",
+			Retries:       1,
+			WorkerOptions: wopts,
+		}, zap.NewNop().Sugar())
+		assert.Error(t, err)
+	}
+}
+
+// countingOutput is a Cloner whose clones share a count of written events.
+type countingOutput struct {
+	recordingOutput
+	count *atomic.Int64
+}
+
+func (o *countingOutput) Write(b []byte) (int, error) {
+	o.count.Add(1)
+	return len(b), nil
+}
+
+func (o *countingOutput) Clone() (Output, error) {
+	return &countingOutput{count: o.count}, nil
+}
+
+func TestWorkersClone(t *testing.T) {
+	var created int
+	count := new(atomic.Int64)
+	Register("counting-test", func(*Options) (Output, error) {
+		created++
+		return &countingOutput{count: count}, nil
+	})
+	t.Cleanup(func() { delete(registry, "counting-test") })
+
+	out, err := Initialize(context.Background(), &Options{
+		Protocol:      "counting-test",
+		Retries:       1,
+		WorkerOptions: WorkerOptions{Workers: 3},
+	}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	for i := 0; i < 6; i++ {
+		_, err = out.Write([]byte("x"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, out.Close())
+
+	// Only the first worker's output is created, the others are its clones.
+	assert.Equal(t, 1, created)
+	assert.EqualValues(t, 6, count.Load())
+}
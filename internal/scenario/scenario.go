@@ -0,0 +1,367 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package scenario runs an ordered list of steps described in a YAML file,
+// such as starting a mock HTTP server, waiting for a port, streaming log and
+// pcap files and sleeping. Steps can be grouped to run in parallel. HTTP servers
+// started by a scenario keep running until the whole scenario is done.
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	ucfg "github.com/elastic/go-ucfg"
+	"github.com/elastic/go-ucfg/yaml"
+	"go.uber.org/zap"
+
+	"github.com/elastic/go-concert/timed"
+
+	"github.com/elastic/stream/internal/cmdutil"
+	"github.com/elastic/stream/internal/httpserver"
+	"github.com/elastic/stream/internal/output"
+	"github.com/elastic/stream/internal/status"
+	"github.com/elastic/stream/pkg/send"
+)
+
+// configOptions are the go-ucfg options used to read scenarios. ${VAR} is
+// replaced with environment variables.
+var configOptions = []ucfg.Option{ucfg.PathSep("."), ucfg.VarExp, ucfg.ResolveEnv}
+
+// Scenario is an ordered list of steps.
+type Scenario struct {
+	Steps []Step `config:"steps"`
+
+	dir string // Directory that relative paths are resolved against.
+}
+
+// Step is one step of a scenario. Exactly one action must be set.
+type Step struct {
+	Name string `config:"name"` // Name used in logs and errors.
+
+	HTTPServer  *HTTPServerStep  `config:"http_server"`   // Start a mock HTTP server.
+	WaitForPort *WaitForPortStep `config:"wait_for_port"` // Wait until a port accepts connections.
+	Log         *SendStep        `config:"log"`           // Stream log files.
+	PCAP        *SendStep        `config:"pcap"`          // Stream pcap files.
+	Sleep       time.Duration    `config:"sleep"`         // Sleep for a duration.
+	Parallel    []Step           `config:"parallel"`      // Run steps concurrently.
+}
+
+// HTTPServerStep starts a mock HTTP server. Its rules are read from the Config
+// file or given inline.
+type HTTPServerStep struct {
+	Addr           string `config:"addr"`     // Listen address.
+	ConfigPath     string `config:"config"`   // Rules config file.
+	TLSCertificate string `config:"tls_cert"` // TLS certificate file path.
+	TLSKey         string `config:"tls_key"`  // TLS key file path.
+
+	httpserver.Config `config:",inline"` // Inline rules, used if ConfigPath is empty.
+}
+
+// WaitForPortStep waits until a port accepts connections.
+type WaitForPortStep struct {
+	Addr     string        `config:"addr"`     // Address (host:port) to connect to.
+	Network  string        `config:"network"`  // Network to connect with. Defaults to tcp.
+	Timeout  time.Duration `config:"timeout"`  // Time to wait for. Defaults to 30s.
+	Interval time.Duration `config:"interval"` // Wait between attempts. Defaults to 250ms.
+}
+
+// SendStep streams files to an output.
+type SendStep struct {
+	Files  []string     `config:"files"`  // File paths or glob patterns.
+	Output *ucfg.Config `config:"output"` // Output options that override the base options.
+}
+
+// Load reads the scenario in the YAML file at path. Relative paths in the
+// scenario are resolved against the directory of the file.
+func Load(path string) (*Scenario, error) {
+	cfg, err := yaml.NewConfigWithFile(path, configOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	s := &Scenario{dir: filepath.Dir(path)}
+	if err = cfg.Unpack(s, configOptions...); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("scenario %s has no steps", path)
+	}
+	if err = validate(s.Steps, ""); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// validate checks that every step has exactly one action.
+func validate(steps []Step, prefix string) error {
+	for i, step := range steps {
+		id := prefix + fmt.Sprint(i+1)
+		actions := 0
+		for _, set := range []bool{step.HTTPServer != nil, step.WaitForPort != nil, step.Log != nil, step.PCAP != nil, step.Sleep > 0, len(step.Parallel) > 0} {
+			if set {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return fmt.Errorf("step %s must have exactly one of http_server, wait_for_port, log, pcap, sleep or parallel", id)
+		}
+		switch {
+		case step.HTTPServer != nil && step.HTTPServer.Addr == "":
+			return fmt.Errorf("step %s: http_server addr is required", id)
+		case step.WaitForPort != nil && step.WaitForPort.Addr == "":
+			return fmt.Errorf("step %s: wait_for_port addr is required", id)
+		case step.Log != nil && len(step.Log.Files) == 0, step.PCAP != nil && len(step.PCAP.Files) == 0:
+			return fmt.Errorf("step %s: files are required", id)
+		}
+		if err := validate(step.Parallel, id+"."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runner holds the state of a running scenario.
+type runner struct {
+	dir    string
+	base   *output.Options
+	status *status.Tracker
+	logger *zap.SugaredLogger
+
+	mu      sync.Mutex
+	servers []*httpserver.Server // Servers to close when the scenario is done.
+}
+
+// Run runs the steps in order. Output options of log and pcap steps override
+// base. The scenario is reported as ready to tracker once the first server is
+// listening or output is connected, and the data sent by all steps is counted.
+// It returns the first step error, after closing the HTTP servers.
+func (s *Scenario) Run(ctx context.Context, base *output.Options, tracker *status.Tracker, logger *zap.SugaredLogger) (err error) {
+	r := &runner{dir: s.dir, base: base, status: tracker, logger: logger}
+	defer func() {
+		err = errors.Join(err, r.close())
+	}()
+
+	for i, step := range s.Steps {
+		if err := r.run(ctx, step, fmt.Sprint(i+1)); err != nil {
+			return err
+		}
+	}
+	logger.Info("Scenario finished.")
+	return nil
+}
+
+func (r *runner) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for _, s := range r.servers {
+		errs = append(errs, s.Close())
+	}
+	r.servers = nil
+	return errors.Join(errs...)
+}
+
+func (r *runner) run(ctx context.Context, step Step, id string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	logger := r.logger.With("step", id)
+	if step.Name != "" {
+		logger = logger.With("step_name", step.Name)
+	}
+	logger.Info("Running step.")
+
+	var err error
+	switch {
+	case step.HTTPServer != nil:
+		err = r.startHTTPServer(ctx, step.HTTPServer, logger)
+	case step.WaitForPort != nil:
+		err = waitForPort(ctx, step.WaitForPort)
+	case step.Log != nil:
+		err = r.send(ctx, step.Log, logger, func(ctx context.Context, f *os.File, out output.Output, opts *output.Options, logger *zap.SugaredLogger) error {
+			_, err := send.Log(ctx, f, out, send.LogOptions{Source: f.Name(), MaxLineSize: opts.MaxLogLineSize, Logger: logger})
+			return err
+		})
+	case step.PCAP != nil:
+		err = r.send(ctx, step.PCAP, logger, func(ctx context.Context, f *os.File, out output.Output, _ *output.Options, logger *zap.SugaredLogger) error {
+			_, err := send.PCAP(ctx, f, out, send.PCAPOptions{Source: f.Name(), Logger: logger})
+			return err
+		})
+	case step.Sleep > 0:
+		err = timed.Wait(ctx, step.Sleep)
+	case len(step.Parallel) > 0:
+		err = r.runParallel(ctx, step.Parallel, id)
+	}
+	if err != nil {
+		if step.Name != "" {
+			return fmt.Errorf("step %s (%s) failed: %w", id, step.Name, err)
+		}
+		return fmt.Errorf("step %s failed: %w", id, err)
+	}
+	return nil
+}
+
+// runParallel runs steps concurrently. The first error cancels the other
+// steps.
+func (r *runner) runParallel(ctx context.Context, steps []Step, id string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(steps))
+	for i, step := range steps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = r.run(ctx, step, fmt.Sprintf("%s.%d", id, i+1)); errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Report the errors that caused the cancellation, not the ones caused by it.
+	var failed []error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		return errors.Join(errs...)
+	}
+	return errors.Join(failed...)
+}
+
+func (r *runner) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(r.dir, p)
+}
+
+func (r *runner) startHTTPServer(ctx context.Context, step *HTTPServerStep, logger *zap.SugaredLogger) error {
+	opts := &httpserver.Options{
+		Options:        &output.Options{Addr: step.Addr},
+		TLSCertificate: r.path(step.TLSCertificate),
+		TLSKey:         r.path(step.TLSKey),
+		ConfigPath:     r.path(step.ConfigPath),
+		ReadTimeout:    5 * time.Second,
+		WriteTimeout:   5 * time.Second,
+	}
+	if step.ConfigPath == "" {
+		opts.Config = &step.Config
+	}
+
+	server, err := httpserver.New(opts, logger)
+	if err != nil {
+		return err
+	}
+	// The server must outlive the step, so it is not tied to the step context.
+	if err = server.Start(context.WithoutCancel(ctx)); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.servers = append(r.servers, server)
+	r.mu.Unlock()
+
+	return r.status.Ready()
+}
+
+func waitForPort(ctx context.Context, step *WaitForPortStep) error {
+	network, timeout, interval := step.Network, step.Timeout, step.Interval
+	if network == "" {
+		network = "tcp"
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	if interval <= 0 {
+		interval = 250 * time.Millisecond
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	for {
+		conn, err := d.DialContext(ctx, network, step.Addr)
+		if err == nil {
+			return conn.Close()
+		}
+		if err := timed.Wait(ctx, interval); err != nil {
+			return fmt.Errorf("%s did not accept connections within %v: %w", step.Addr, timeout, err)
+		}
+	}
+}
+
+type sendFunc func(ctx context.Context, f *os.File, out output.Output, opts *output.Options, logger *zap.SugaredLogger) error
+
+// send initializes the output of step and sends each of its files with fn.
+func (r *runner) send(ctx context.Context, step *SendStep, logger *zap.SugaredLogger, fn sendFunc) (err error) {
+	// The step's output options are unpacked into a deep copy of the base
+	// options, so that they don't leak into the base or other steps.
+	opts := r.base.Clone()
+	if step.Output != nil {
+		if err := step.Output.Unpack(opts, configOptions...); err != nil {
+			return fmt.Errorf("invalid output: %w", err)
+		}
+	}
+
+	patterns := make([]string, 0, len(step.Files))
+	for _, f := range step.Files {
+		patterns = append(patterns, r.path(f))
+	}
+	files, err := cmdutil.ExpandGlobPatternsFromArgs(patterns)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files match %v", patterns)
+	}
+
+	logger = logger.With("address", opts.Addr)
+	out, err := output.Initialize(ctx, opts, logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, out.Close())
+	}()
+
+	if err := r.status.Ready(); err != nil {
+		return err
+	}
+	out = r.status.Output(out)
+
+	for _, path := range files {
+		if err := sendFile(ctx, path, out, opts, logger, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sendFile(ctx context.Context, path string, out output.Output, opts *output.Options, logger *zap.SugaredLogger, fn sendFunc) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err = fn(ctx, f, out, opts, logger.With("file", path)); err != nil {
+		return fmt.Errorf("failed to send %s: %w", path, err)
+	}
+	return nil
+}
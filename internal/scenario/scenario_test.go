@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package scenario
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/elastic/stream/internal/output"
+	_ "github.com/elastic/stream/internal/output/file"
+	_ "github.com/elastic/stream/internal/output/webhook"
+	"github.com/elastic/stream/internal/status"
+)
+
+// freeAddr returns a local address that is not in use.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// writeScenario writes content and the files it references to a temporary
+// directory and returns the scenario path.
+func writeScenario(t *testing.T, content string, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+	}
+	path := filepath.Join(dir, "scenario.yml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv("TEST_SCENARIO_ADDR", freeAddr(t))
+	out := filepath.Join(t.TempDir(), "out.log")
+	t.Setenv("TEST_SCENARIO_OUT", out)
+
+	path := writeScenario(t, `
+steps:
+  - name: mock
+    http_server:
+      addr: ${TEST_SCENARIO_ADDR}
+      rules:
+        - path: /events
+          methods: [POST]
+          responses:
+            - status_code: 202
+  - wait_for_port:
+      addr: ${TEST_SCENARIO_ADDR}
+  - parallel:
+      - log:
+          files: [a.log]
+          output:
+            protocol: webhook
+            addr: http://${TEST_SCENARIO_ADDR}/events
+            webhook:
+              expect_status: [202]
+      - log:
+          files: ["*.log"]
+          output:
+            protocol: file
+            addr: ${TEST_SCENARIO_OUT}
+  - sleep: 10ms
+`, map[string]string{"a.log": "a1\na2\n", "b.log": "b1\n"})
+
+	s, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, s.Steps, 4)
+
+	base := &output.Options{
+		Protocol:       "tcp",
+		Retries:        1,
+		MaxLogLineSize: 1024,
+		WebhookOptions: output.WebhookOptions{ExpectStatus: []string{"2xx"}},
+	}
+	tracker := status.New(&status.Options{}, zap.NewNop().Sugar())
+	require.NoError(t, s.Run(context.Background(), base, tracker, zap.NewNop().Sugar()))
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "a1\na2\nb1\n", string(data))
+	assert.Equal(t, "tcp", base.Protocol, "base options are not modified")
+	assert.Equal(t, []string{"2xx"}, base.WebhookOptions.ExpectStatus, "base options are not modified")
+	assert.Equal(t, status.Sending, tracker.Snapshot().State)
+	assert.EqualValues(t, 5, tracker.Snapshot().Events, "events sent by all steps are counted")
+
+	// The HTTP server is stopped when the scenario is done.
+	_, err = net.Dial("tcp", os.Getenv("TEST_SCENARIO_ADDR"))
+	assert.Error(t, err)
+}
+
+func TestRunStepFailure(t *testing.T) {
+	addr := freeAddr(t)
+	t.Setenv("TEST_SCENARIO_ADDR", addr)
+
+	path := writeScenario(t, `
+steps:
+  - http_server:
+      addr: ${TEST_SCENARIO_ADDR}
+      rules:
+        - path: /events
+          responses:
+            - status_code: 500
+  - parallel:
+      - sleep: 1h
+      - name: send
+        log:
+          files: [a.log]
+          output:
+            protocol: webhook
+            addr: http://${TEST_SCENARIO_ADDR}/events
+            webhook:
+              retry_max: 0
+`, map[string]string{"a.log": "a1\n"})
+
+	s, err := Load(path)
+	require.NoError(t, err)
+
+	err = s.Run(context.Background(), &output.Options{Retries: 1}, status.New(&status.Options{}, zap.NewNop().Sugar()), zap.NewNop().Sugar())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "step 2.2 (send) failed")
+	assert.NotContains(t, err.Error(), "context canceled", "steps cancelled by the failure are not reported")
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"no steps":         "steps: []",
+		"no action":        "steps: [{name: empty}]",
+		"two actions":      "steps: [{sleep: 1s, wait_for_port: {addr: 'localhost:1'}}]",
+		"no addr":          "steps: [{wait_for_port: {timeout: 1s}}]",
+		"no files":         "steps: [{log: {output: {protocol: tcp}}}]",
+		"invalid parallel": "steps: [{parallel: [{sleep: 1s}, {name: empty}]}]",
+		"bad duration":     "steps: [{sleep: soon}]",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(writeScenario(t, content, nil))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWaitForPortTimeout(t *testing.T) {
+	err := waitForPort(context.Background(), &WaitForPortStep{Addr: freeAddr(t), Timeout: 100_000_000})
+	assert.ErrorContains(t, err, "did not accept connections")
+}
@@ -0,0 +1,281 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package status tracks the state of a stream command and reports it to other
+// processes with a ready file, a done file, an HTTP health endpoint and the
+// systemd notification socket.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/elastic/go-concert/timed"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+// State is the state of a command.
+type State string
+
+// States in the order they are reached. Failed replaces Finished if the
+// command returns an error.
+const (
+	Waiting   State = "waiting"   // Waiting for the start signal, delay or connection.
+	Connected State = "connected" // The output is connected or the server is listening.
+	Sending   State = "sending"   // Data is being written to the output.
+	Finished  State = "finished"  // All data was sent.
+	Failed    State = "failed"    // The command failed.
+)
+
+// HealthPath is the path of the health endpoint.
+const HealthPath = "/healthz"
+
+// Options holds the configuration of the status reporting.
+type Options struct {
+	ReadyFile    string        // File written when the command is ready.
+	DoneFile     string        // File written when the command finished successfully.
+	HealthAddr   string        // Listen address of the health endpoint. Empty disables it.
+	HealthLinger time.Duration // Time the health endpoint keeps serving after the command is done.
+}
+
+// Snapshot is the status reported by the health endpoint and written to the
+// ready and done files.
+type Snapshot struct {
+	State  State  `json:"state"`
+	Events int64  `json:"events"`
+	Bytes  int64  `json:"bytes"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Tracker tracks the state of a command and the data it sent.
+type Tracker struct {
+	opts   *Options
+	logger *zap.SugaredLogger
+
+	mu    sync.Mutex
+	state State
+	err   error
+
+	events atomic.Int64
+	bytes  atomic.Int64
+
+	readyOnce sync.Once
+	readyErr  error
+
+	ctx     context.Context // Context of the health endpoint, nil if it is not started.
+	serving bool
+}
+
+// New returns a Tracker in the Waiting state. opts is read when the tracker is
+// used, so it can be bound to flags before they are parsed.
+func New(opts *Options, logger *zap.SugaredLogger) *Tracker {
+	return &Tracker{opts: opts, logger: logger, state: Waiting}
+}
+
+// Snapshot returns the current status.
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := Snapshot{State: t.state, Events: t.events.Load(), Bytes: t.bytes.Load()}
+	if t.err != nil {
+		s.Error = t.err.Error()
+	}
+	return s
+}
+
+// setState moves to state unless the tracker is already in a later state.
+func (t *Tracker) setState(state State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if order(state) > order(t.state) {
+		t.state = state
+	}
+}
+
+func order(s State) int {
+	switch s {
+	case Waiting:
+		return 0
+	case Connected:
+		return 1
+	case Sending:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Start starts the health endpoint if it is configured. It is shut down when
+// ctx is done.
+func (t *Tracker) Start(ctx context.Context) error {
+	if t.opts.HealthAddr == "" {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", t.opts.HealthAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on health address: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(HealthPath, t)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+			t.logger.Warnw("Health endpoint stopped.", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	t.ctx, t.serving = ctx, true
+	t.logger.Infow("Health endpoint listening.", "health_addr", l.Addr().String())
+	return nil
+}
+
+// ServeHTTP implements http.Handler. It responds with the status as JSON. The
+// status code is 200 once the command is ready and 503 while it is waiting or
+// after it failed.
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s := t.Snapshot()
+
+	code := http.StatusOK
+	if s.State == Waiting || s.State == Failed {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(s) //nolint:errcheck // Nothing to do if the client is gone.
+}
+
+// Ready moves to the Connected state. The first call writes the ready file and
+// notifies systemd.
+func (t *Tracker) Ready() error {
+	t.setState(Connected)
+	t.readyOnce.Do(func() {
+		t.readyErr = errors.Join(
+			t.writeFile(t.opts.ReadyFile),
+			notify("READY=1\nSTATUS="+string(Connected)),
+		)
+	})
+	return t.readyErr
+}
+
+// Done moves to the Finished state, or to Failed if err is not nil. On success
+// the done file is written. If the health endpoint is running, Done waits for
+// the HealthLinger option so that it can report the final state before the
+// process exits.
+func (t *Tracker) Done(err error) error {
+	t.mu.Lock()
+	if err != nil {
+		t.state, t.err = Failed, err
+	} else {
+		t.state = Finished
+	}
+	t.mu.Unlock()
+
+	var notifyErr error
+	if err != nil {
+		notifyErr = notify("STATUS=" + string(Failed) + ": " + strings.ReplaceAll(err.Error(), "\n", " "))
+	} else {
+		notifyErr = errors.Join(
+			t.writeFile(t.opts.DoneFile),
+			notify("STATUS="+string(Finished)),
+		)
+	}
+
+	if t.serving && t.opts.HealthLinger > 0 {
+		t.logger.Infow("Health endpoint lingering.", "health_linger", t.opts.HealthLinger)
+		// An interrupt ends the wait early.
+		_ = timed.Wait(t.ctx, t.opts.HealthLinger)
+	}
+	return notifyErr
+}
+
+// writeFile writes the status as JSON to path. It is written to a temporary
+// file that is renamed, so readers never see a partial file.
+func (t *Tracker) writeFile(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(t.Snapshot())
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	return nil
+}
+
+// Output returns out wrapped to count the events and bytes written to it. The
+// first write moves to the Sending state.
+func (t *Tracker) Output(out output.Output) output.Output {
+	return &countingOutput{Output: out, tracker: t}
+}
+
+type countingOutput struct {
+	output.Output
+	tracker *Tracker
+}
+
+func (c *countingOutput) Write(b []byte) (int, error) {
+	c.tracker.setState(Sending)
+	n, err := c.Output.Write(b)
+	if err == nil {
+		c.tracker.events.Add(1)
+		c.tracker.bytes.Add(int64(n))
+	}
+	return n, err
+}
+
+// StartSource implements output.SourceOutput.
+func (c *countingOutput) StartSource(path string) error {
+	return output.StartSource(c.Output, path)
+}
+
+// notify sends state to the systemd notification socket in NOTIFY_SOCKET. It
+// is a no-op if the variable is not set.
+func notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading @ refers to an abstract socket.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd notification socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type nopOutput struct{}
+
+func (nopOutput) DialContext(context.Context) error { return nil }
+func (nopOutput) Close() error                      { return nil }
+func (nopOutput) Write(b []byte) (int, error)       { return len(b), nil }
+
+func readSnapshot(t *testing.T, path string) Snapshot {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var s Snapshot
+	require.NoError(t, json.Unmarshal(b, &s))
+	return s
+}
+
+func TestTracker(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{
+		ReadyFile: filepath.Join(dir, "ready"),
+		DoneFile:  filepath.Join(dir, "done"),
+	}
+	tracker := New(opts, zap.NewNop().Sugar())
+	assert.Equal(t, Snapshot{State: Waiting}, tracker.Snapshot())
+
+	require.NoError(t, tracker.Ready())
+	assert.Equal(t, Snapshot{State: Connected}, readSnapshot(t, opts.ReadyFile))
+
+	out := tracker.Output(nopOutput{})
+	for _, event := range []string{"one", "three"} {
+		_, err := out.Write([]byte(event))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, Snapshot{State: Sending, Events: 2, Bytes: 8}, tracker.Snapshot())
+
+	// A later Ready does not move back to connected.
+	require.NoError(t, tracker.Ready())
+	assert.Equal(t, Sending, tracker.Snapshot().State)
+
+	require.NoError(t, tracker.Done(nil))
+	assert.Equal(t, Snapshot{State: Finished, Events: 2, Bytes: 8}, readSnapshot(t, opts.DoneFile))
+}
+
+func TestTrackerFailed(t *testing.T) {
+	opts := &Options{DoneFile: filepath.Join(t.TempDir(), "done")}
+	tracker := New(opts, zap.NewNop().Sugar())
+
+	require.NoError(t, tracker.Done(errors.New("boom")))
+	assert.Equal(t, Snapshot{State: Failed, Error: "boom"}, tracker.Snapshot())
+	assert.NoFileExists(t, opts.DoneFile)
+}
+
+func TestServeHTTP(t *testing.T) {
+	tracker := New(&Options{}, zap.NewNop().Sugar())
+
+	get := func() (int, Snapshot) {
+		rec := httptest.NewRecorder()
+		tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+		var s Snapshot
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+		return rec.Code, s
+	}
+
+	code, s := get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, Waiting, s.State)
+
+	require.NoError(t, tracker.Ready())
+	code, s = get()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, Connected, s.State)
+
+	require.NoError(t, tracker.Done(errors.New("boom")))
+	code, s = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, Snapshot{State: Failed, Error: "boom"}, s)
+}
+
+func TestStart(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracker := New(&Options{HealthAddr: addr}, zap.NewNop().Sugar())
+	require.NoError(t, tracker.Start(ctx))
+	require.NoError(t, tracker.Ready())
+
+	resp, err := http.Get("http://" + addr + HealthPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+}
+
+func TestHealthLinger(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracker := New(&Options{HealthAddr: addr, HealthLinger: time.Minute}, zap.NewNop().Sugar())
+	require.NoError(t, tracker.Start(ctx))
+
+	done := make(chan error, 1)
+	go func() { done <- tracker.Done(nil) }()
+
+	// The endpoint reports the final state until the linger ends.
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr + HealthPath)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		var s Snapshot
+		return json.NewDecoder(resp.Body).Decode(&s) == nil && s.State == Finished
+	}, 5*time.Second, 10*time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("Done returned before the linger ended")
+	default:
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done did not return after the context was canceled")
+	}
+}
+
+func TestNotify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	tracker := New(&Options{}, zap.NewNop().Sugar())
+	require.NoError(t, tracker.Ready())
+	require.NoError(t, tracker.Done(nil))
+
+	buf := make([]byte, 256)
+	for _, want := range []string{"READY=1\nSTATUS=connected", "STATUS=finished"} {
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(buf[:n]))
+	}
+}
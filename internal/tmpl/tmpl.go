@@ -0,0 +1,104 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package tmpl provides the Go template helper functions that are shared by the
+// http-server response templates and the output templates.
+package tmpl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Funcs returns the helper functions available in templates.
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"env":         env,
+		"hostname":    hostname,
+		"sum":         sum,
+		"file":        file,
+		"glob":        filepath.Glob,
+		"json":        toJSON,
+		"minify_json": minify,
+		"now":         now,
+		"uuid":        newUUID,
+	}
+}
+
+// Parse parses text as a template that has the helper functions. Missing map
+// keys evaluate to the zero value.
+func Parse(name, text string) (*template.Template, error) {
+	return template.New(name).
+		Option("missingkey=zero").
+		Funcs(Funcs()).
+		Parse(text)
+}
+
+func env(key string) string {
+	return os.Getenv(key)
+}
+
+func hostname() string {
+	h, _ := os.Hostname()
+	return h
+}
+
+func sum(a, b int) int {
+	return a + b
+}
+
+func file(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// toJSON returns v encoded as JSON without HTML escaping.
+func toJSON(v interface{}) (string, error) {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func minify(body string) (string, error) {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(json.RawMessage(body))
+	return strings.TrimSpace(buf.String()), err
+}
+
+// now returns the current UTC time. An optional Go duration string
+// offsets the result (e.g. "-720h" for 30 days ago). The returned
+// time.Time value exposes its methods to templates, so callers can
+// format it as needed: {{ (now).Format "2006-01-02" }}.
+func now(offset ...string) (time.Time, error) {
+	t := time.Now().UTC()
+	if len(offset) == 0 {
+		return t, nil
+	}
+	d, err := time.ParseDuration(offset[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid duration %q: %w", offset[0], err)
+	}
+	return t.Add(d), nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	return uuid.NewString()
+}
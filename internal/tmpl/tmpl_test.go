@@ -0,0 +1,88 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package tmpl
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNow(t *testing.T) {
+	t.Run("no offset", func(t *testing.T) {
+		before := time.Now().UTC()
+		got, err := now()
+		if err != nil {
+			t.Fatalf("now() error: %v", err)
+		}
+		if got.Before(before.Add(-time.Second)) || got.After(time.Now().UTC().Add(time.Second)) {
+			t.Errorf("now() = %s; want within 1s of current time", got)
+		}
+	})
+
+	t.Run("negative offset", func(t *testing.T) {
+		before := time.Now().UTC().Add(-24 * time.Hour)
+		got, err := now("-24h")
+		if err != nil {
+			t.Fatalf("now(%q) error: %v", "-24h", err)
+		}
+		if got.Before(before.Add(-time.Second)) || got.After(before.Add(time.Second)) {
+			t.Errorf("now(%q) = %s; want within 1s of %s", "-24h", got, before)
+		}
+	})
+
+	t.Run("positive offset", func(t *testing.T) {
+		expected := time.Now().UTC().Add(2 * time.Hour)
+		got, err := now("2h")
+		if err != nil {
+			t.Fatalf("now(%q) error: %v", "2h", err)
+		}
+		if got.Before(expected.Add(-time.Second)) || got.After(expected.Add(time.Second)) {
+			t.Errorf("now(%q) = %s; want within 1s of %s", "2h", got, expected)
+		}
+	})
+
+	t.Run("invalid offset", func(t *testing.T) {
+		_, err := now("bogus")
+		if err == nil {
+			t.Error("now(\"bogus\") error = nil; want error")
+		}
+	})
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{in: "a <b>", want: `"a <b>"`},
+		{in: map[string]int{"a": 1}, want: `{"a":1}`},
+		{in: json.RawMessage(`{"a": 1}`), want: `{"a":1}`},
+	}
+	for _, test := range tests {
+		got, err := toJSON(test.in)
+		if err != nil {
+			t.Fatalf("json(%v) error: %v", test.in, err)
+		}
+		if got != test.want {
+			t.Errorf("json(%v) = %s; want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tpl, err := Parse("", `{{ .name | json }} {{ uuid | len }}`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	var buf strings.Builder
+	if err := tpl.Execute(&buf, map[string]string{"name": "stream"}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if got, want := buf.String(), `"stream" 36`; got != want {
+		t.Errorf("Execute = %s; want %s", got, want)
+	}
+}
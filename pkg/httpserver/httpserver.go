@@ -0,0 +1,48 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package httpserver is the public API of the stream mock HTTP server. Rules can
+// be loaded from a YAML file like the http-server command does, or be built in
+// code:
+//
+//	server, err := httpserver.New(&httpserver.Options{
+//		Options: &output.Options{Addr: "localhost:0"},
+//		Config: &httpserver.Config{Rules: []httpserver.Rule{{
+//			Path:      "/api/events",
+//			Methods:   []string{"POST"},
+//			Responses: []httpserver.Response{{StatusCode: 202}},
+//		}}},
+//	}, nil)
+package httpserver
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/elastic/stream/internal/httpserver"
+)
+
+// Server is a mock HTTP server. Start it with Start, and use Addr to get the
+// listening address.
+type Server = httpserver.Server
+
+// Options are the options for the mock server.
+type Options = httpserver.Options
+
+// Config holds the rules of the mock server.
+type Config = httpserver.Config
+
+// Rule matches requests and defines the responses returned for them.
+type Rule = httpserver.Rule
+
+// Response is a response returned for a matched request. Headers and Body are
+// Go templates with the same functions and data as the YAML rules.
+type Response = httpserver.Response
+
+// New creates a new mock server. A nil logger discards log messages.
+func New(opts *Options, logger *zap.SugaredLogger) (*Server, error) {
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	return httpserver.New(opts, logger)
+}
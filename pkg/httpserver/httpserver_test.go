@@ -0,0 +1,58 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package httpserver_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/stream/pkg/httpserver"
+	"github.com/elastic/stream/pkg/output"
+	"github.com/elastic/stream/pkg/send"
+)
+
+// TestLibrary sends a log to the mock server through a webhook output using
+// only the public packages.
+func TestLibrary(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := httpserver.New(&httpserver.Options{
+		Options: &output.Options{Addr: "localhost:0"},
+		Config: &httpserver.Config{
+			AsSequence: true,
+			Rules: []httpserver.Rule{
+				{Path: "/events", Methods: []string{"POST"}, RequestBody: "first", Responses: []httpserver.Response{{StatusCode: 202}}},
+				{Path: "/events", Methods: []string{"POST"}, RequestBody: "second", Responses: []httpserver.Response{{StatusCode: 202}}},
+			},
+		},
+		AccessLog: io.Discard,
+	}, nil)
+	require.NoError(t, err)
+	require.NoError(t, server.Start(ctx))
+	defer server.Close()
+
+	out, err := output.Initialize(ctx, &output.Options{
+		Protocol: "webhook",
+		Addr:     "http://" + server.Addr().String() + "/events",
+		WebhookOptions: output.WebhookOptions{
+			ContentType:  "text/plain",
+			ExpectStatus: []string{"202"},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	stats, err := send.Log(ctx, strings.NewReader("first\nsecond\n"), out, send.LogOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, send.Stats{Bytes: 11, Events: 2}, stats)
+	require.NoError(t, out.Close())
+
+	assert.Contains(t, output.Available(), "webhook")
+}
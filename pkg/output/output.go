@@ -0,0 +1,100 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package output is the public API for constructing stream outputs from Go
+// code. All outputs that ship with stream are registered when this package is
+// imported.
+//
+//	out, err := output.Initialize(ctx, &output.Options{Protocol: "tcp", Addr: "localhost:9000"}, nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer out.Close()
+package output
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/elastic/stream/internal/output"
+
+	// Register all outputs.
+	_ "github.com/elastic/stream/internal/output/azureblobstorage"
+	_ "github.com/elastic/stream/internal/output/azureeventhub"
+	_ "github.com/elastic/stream/internal/output/file"
+	_ "github.com/elastic/stream/internal/output/gcppubsub"
+	_ "github.com/elastic/stream/internal/output/gcs"
+	_ "github.com/elastic/stream/internal/output/grpc"
+	_ "github.com/elastic/stream/internal/output/kafka"
+	_ "github.com/elastic/stream/internal/output/lumberjack"
+	_ "github.com/elastic/stream/internal/output/net"
+	_ "github.com/elastic/stream/internal/output/webhook"
+	_ "github.com/elastic/stream/internal/output/websocket"
+)
+
+// Output is an io.WriteCloser that can be dialed.
+type Output = output.Output
+
+// SourceOutput is an Output that needs to know which input the data it writes
+// was read from.
+type SourceOutput = output.SourceOutput
+
+// BatchOutput is an Output that can write several events at once.
+type BatchOutput = output.BatchOutput
+
+// Factory is a function that creates a new Output.
+type Factory = output.Factory
+
+// Options holds the configuration for an output. Fields left at their zero
+// value use the output's defaults, and a Retries below one still makes a
+// single dial attempt.
+type Options = output.Options
+
+// Option groups embedded in Options.
+type (
+	TLSOptions              = output.TLSOptions
+	NetOptions              = output.NetOptions
+	ReconnectOptions        = output.ReconnectOptions
+	RateLimitOptions        = output.RateLimitOptions
+	WorkerOptions           = output.WorkerOptions
+	BatchOptions            = output.BatchOptions
+	WebhookOptions          = output.WebhookOptions
+	GCPPubsubOptions        = output.GCPPubsubOptions
+	KafkaOptions            = output.KafkaOptions
+	AzureBlobStorageOptions = output.AzureBlobStorageOptions
+	AzureEventHubOptions    = output.AzureEventHubOptions
+	LumberjackOptions       = output.LumberjackOptions
+	GCSOptions              = output.GCSOptions
+	WebSocketOptions        = output.WebSocketOptions
+	GRPCOptions             = output.GRPCOptions
+	FanoutOutput            = output.FanoutOutput
+)
+
+// Initialize creates the output described by opts and connects it, with the
+// same retries, reconnects, workers, rate limits, batching and fan-out as the
+// stream commands. A nil logger discards log messages.
+func Initialize(ctx context.Context, opts *Options, logger *zap.SugaredLogger) (Output, error) {
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	return output.Initialize(ctx, opts, logger)
+}
+
+// New creates an unconnected output for opts.Protocol. Call DialContext before
+// writing to it.
+func New(opts *Options) (Output, error) {
+	return output.New(opts)
+}
+
+// Register registers a factory for a custom protocol. It is not thread-safe
+// and should be called from init functions.
+func Register(protocol string, factory Factory) {
+	output.Register(protocol, factory)
+}
+
+// Available returns a sorted list of the registered protocols.
+func Available() []string {
+	return output.Available()
+}
@@ -0,0 +1,48 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package output_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/stream/pkg/output"
+)
+
+// TestInitializeTCP checks that Initialize dials a tcp output when Retries is
+// left at its zero value.
+func TestInitializeTCP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	out, err := output.Initialize(ctx, &output.Options{Protocol: "tcp", Addr: l.Addr().String()}, nil)
+	require.NoError(t, err)
+
+	_, err = out.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	assert.Equal(t, "hello\n", <-received)
+}
@@ -0,0 +1,60 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package send
+
+import (
+	"bufio"
+	"context"
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+// LogOptions configures Log.
+type LogOptions struct {
+	Source      string             // Name of the data source passed to outputs that track it (e.g. a file path).
+	MaxLineSize int                // Maximum line size in bytes. Defaults to DefaultMaxLineSize.
+	Logger      *zap.SugaredLogger // Logger for progress messages. Defaults to a no-op logger.
+}
+
+// Log writes each line read from r to out. It stops early without an error
+// when ctx is cancelled.
+func Log(ctx context.Context, r io.Reader, out output.Output, opts LogOptions) (Stats, error) {
+	logger := loggerOrNop(opts.Logger)
+	maxLineSize := opts.MaxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+
+	var stats Stats
+	if err := output.StartSource(out, opts.Source); err != nil {
+		return stats, err
+	}
+
+	s := bufio.NewScanner(bufio.NewReader(r))
+	s.Buffer(make([]byte, maxLineSize), maxLineSize)
+	for s.Scan() {
+		if ctx.Err() != nil {
+			break
+		}
+
+		logger.Debugw("Sending log line.", "line_number", stats.Events+1)
+		n, err := out.Write(s.Bytes())
+		if err != nil {
+			return stats, err
+		}
+
+		stats.Bytes += n
+		stats.Events++
+	}
+	if s.Err() != nil {
+		return stats, s.Err()
+	}
+
+	logger.Infow("Log data sent.", "total_bytes", stats.Bytes, "total_lines", stats.Events)
+	return stats, nil
+}
@@ -0,0 +1,41 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package send
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	out := &memoryOutput{}
+	stats, err := Log(context.Background(), strings.NewReader("one\ntwo\n\nthree"), out, LogOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two", "", "three"}, out.payloads())
+	assert.Equal(t, Stats{Bytes: 11, Events: 4}, stats)
+}
+
+func TestLogMaxLineSize(t *testing.T) {
+	out := &memoryOutput{}
+	stats, err := Log(context.Background(), strings.NewReader("short\n"+strings.Repeat("x", 32)), out, LogOptions{MaxLineSize: 16})
+	assert.ErrorIs(t, err, bufio.ErrTooLong)
+	assert.Equal(t, []string{"short"}, out.payloads())
+	assert.Equal(t, 1, stats.Events)
+}
+
+func TestLogRespectsContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	out := &memoryOutput{}
+	_, err := Log(ctx, strings.NewReader("one\ntwo\n"), out, LogOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, out.payloads())
+}
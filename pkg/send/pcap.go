@@ -0,0 +1,133 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package send
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"go.uber.org/zap"
+
+	"github.com/elastic/stream/internal/output"
+)
+
+// PCAPOptions configures PCAP.
+type PCAPOptions struct {
+	Source string             // Name of the data source passed to outputs that track it (e.g. a file path).
+	Logger *zap.SugaredLogger // Logger for progress messages. Defaults to a no-op logger.
+}
+
+// pcapNgMagic is the block type of the Section Header Block that begins every
+// pcapng file. It is palindromic, so it identifies the format regardless of the
+// byte order the file was written in.
+var pcapNgMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// gzipMagic is the two byte header that begins every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// newPacketReader returns a packet source for the pcap or pcapng data in r,
+// along with the link type of its packets. Gzip compressed captures are
+// decompressed transparently.
+func newPacketReader(r io.Reader) (gopacket.PacketDataSource, layers.LinkType, error) {
+	br := bufio.NewReader(r)
+
+	if magic, err := br.Peek(len(gzipMagic)); err == nil && bytes.Equal(magic, gzipMagic) {
+		gzipReader, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		br = bufio.NewReader(gzipReader)
+	}
+
+	magic, err := br.Peek(len(pcapNgMagic))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read capture file header: %w", err)
+	}
+
+	if bytes.Equal(magic, pcapNgMagic) {
+		opts := pcapgo.DefaultNgReaderOptions
+		// The defaults already mirror libpcap: the link type comes from the
+		// first interface and packets from interfaces with a differing link
+		// type are ignored. Skipping unknown section versions is recommended by
+		// the pcapng spec and matches libpcap, which would otherwise leave a
+		// capture containing a newer section unreadable.
+		opts.SkipUnknownVersion = true
+
+		reader, err := pcapgo.NewNgReader(br, opts)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid pcapng header: %w", err)
+		}
+		return reader, reader.LinkType(), nil
+	}
+
+	reader, err := pcapgo.NewReader(br)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid pcap header: %w", err)
+	}
+	return reader, reader.LinkType(), nil
+}
+
+// PCAP writes the transport layer payload of each packet in the pcap or pcapng
+// capture read from r to out. Gzip compressed captures are supported. Packets
+// without a transport layer are skipped, and a truncated capture is sent up to
+// the last complete packet. It stops early without an error when ctx is
+// cancelled.
+func PCAP(ctx context.Context, r io.Reader, out output.Output, opts PCAPOptions) (Stats, error) {
+	logger := loggerOrNop(opts.Logger)
+
+	var stats Stats
+	if err := output.StartSource(out, opts.Source); err != nil {
+		return stats, err
+	}
+
+	source, linkType, err := newPacketReader(r)
+	if err != nil {
+		return stats, err
+	}
+
+	// Process packets in PCAP and get flow records.
+readPackets:
+	for ctx.Err() == nil {
+		data, _, err := source.ReadPacketData()
+		switch {
+		case err == nil:
+		case errors.Is(err, io.EOF):
+			// End of the capture.
+			break readPackets
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			// Tolerate truncated captures and stream what was readable.
+			logger.Warnw("Capture file is truncated, stopping early", "total_packets", stats.Events)
+			break readPackets
+		default:
+			return stats, fmt.Errorf("failed to read packet %d: %w", stats.Events+1, err)
+		}
+
+		packet := gopacket.NewPacket(data, linkType, gopacket.Default)
+
+		tl := packet.TransportLayer()
+		if tl == nil {
+			logger.Warnw("Skipping packet with no transport layer")
+			continue
+		}
+
+		n, err := out.Write(tl.LayerPayload())
+		if err != nil {
+			return stats, err
+		}
+		stats.Bytes += n
+		stats.Events++
+	}
+
+	logger.Infow("Sent PCAP payload data", "total_bytes", stats.Bytes, "total_packets", stats.Events)
+	return stats, nil
+}
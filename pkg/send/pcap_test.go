@@ -2,7 +2,7 @@
 // Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
 // See the LICENSE file in the project root for more information.
 
-package command
+package send
 
 import (
 	"bytes"
@@ -17,10 +17,8 @@ import (
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcapgo"
-	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.uber.org/zap"
 )
 
 // helpers
@@ -133,15 +131,15 @@ func (m *memoryOutput) payloads() []string {
 	return out
 }
 
-func newTestRunner(t *testing.T) *pcapRunner {
+// sendPCAPFile sends the capture file at path to out.
+func sendPCAPFile(t *testing.T, ctx context.Context, path string, out *memoryOutput) (Stats, error) {
 	t.Helper()
 
-	cmd := &cobra.Command{}
-	cmd.SetContext(context.Background())
-	return &pcapRunner{
-		logger: zap.NewNop().Sugar(),
-		cmd:    cmd,
-	}
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	return PCAP(ctx, f, out, PCAPOptions{Source: path})
 }
 
 // tests
@@ -241,8 +239,10 @@ func TestSendPCAP(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			out := &memoryOutput{}
-			require.NoError(t, newTestRunner(t).sendPCAP(tc.path(t), out))
+			stats, err := sendPCAPFile(t, context.Background(), tc.path(t), out)
+			require.NoError(t, err)
 			assert.Equal(t, []string{"one", "two"}, out.payloads())
+			assert.Equal(t, Stats{Bytes: 6, Events: 2}, stats)
 		})
 	}
 }
@@ -272,7 +272,8 @@ func TestSendPCAPSkipsPacketsWithoutTransportLayer(t *testing.T) {
 	path := writePCAP(t, buf.Bytes(), udpPacket(t, "payload"))
 
 	out := &memoryOutput{}
-	require.NoError(t, newTestRunner(t).sendPCAP(path, out))
+	_, err := sendPCAPFile(t, context.Background(), path, out)
+	require.NoError(t, err)
 	assert.Equal(t, []string{"payload"}, out.payloads())
 }
 
@@ -289,7 +290,8 @@ func TestSendPCAPTruncated(t *testing.T) {
 	require.NoError(t, os.WriteFile(truncated, raw[:len(raw)-8], 0o600))
 
 	out := &memoryOutput{}
-	require.NoError(t, newTestRunner(t).sendPCAP(truncated, out))
+	_, err = sendPCAPFile(t, context.Background(), truncated, out)
+	require.NoError(t, err)
 	assert.Equal(t, []string{"first"}, out.payloads())
 }
 
@@ -312,7 +314,7 @@ func TestSendPCAPCorruptRecord(t *testing.T) {
 	require.NoError(t, os.WriteFile(corruptPath, corrupt, 0o600))
 
 	out := &memoryOutput{}
-	err = newTestRunner(t).sendPCAP(corruptPath, out)
+	_, err = sendPCAPFile(t, context.Background(), corruptPath, out)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to read packet 2")
 }
@@ -322,12 +324,11 @@ func TestSendPCAPCorruptRecord(t *testing.T) {
 func TestSendPCAPRespectsContextCancellation(t *testing.T) {
 	path := writePCAP(t, udpPacket(t, "one"), udpPacket(t, "two"))
 
-	r := newTestRunner(t)
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	r.cmd.SetContext(ctx)
 
 	out := &memoryOutput{}
-	require.NoError(t, r.sendPCAP(path, out))
+	_, err := sendPCAPFile(t, ctx, path, out)
+	require.NoError(t, err)
 	assert.Empty(t, out.payloads())
 }
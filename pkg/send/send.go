@@ -0,0 +1,28 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package send streams log and pcap data from an io.Reader to an output. It is
+// the library counterpart of the log and pcap commands.
+package send
+
+import (
+	"go.uber.org/zap"
+)
+
+// Stats describes the data that was sent.
+type Stats struct {
+	Bytes  int // Bytes written to the output.
+	Events int // Events (log lines or packet payloads) written to the output.
+}
+
+// DefaultMaxLineSize is the log line buffer size used when LogOptions does not
+// set one.
+const DefaultMaxLineSize = 500 * 1024
+
+func loggerOrNop(logger *zap.SugaredLogger) *zap.SugaredLogger {
+	if logger == nil {
+		return zap.NewNop().Sugar()
+	}
+	return logger
+}